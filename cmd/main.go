@@ -7,12 +7,15 @@ import (
 
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	httppb "github.com/10664kls/app-in-performance-api/genproto/go/http/v1"
+	"github.com/10664kls/app-in-performance-api/internal/alert"
 	"github.com/10664kls/app-in-performance-api/internal/appin"
 	"github.com/10664kls/app-in-performance-api/internal/server"
+	"github.com/10664kls/app-in-performance-api/internal/view"
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	"github.com/labstack/echo/v4"
 	stdmw "github.com/labstack/echo/v4/middleware"
@@ -44,28 +47,139 @@ func run() error {
 	zlog.Info("Logger replaced in globals")
 	zlog.Info("Logger initialized")
 
+	var slaPolicy *appin.SLAPolicy
+	if name := os.Getenv("SLA_POLICY_FILE"); name != "" {
+		slaPolicy, err = appin.ReadSLAPolicyFile(name)
+		if err != nil {
+			return fmt.Errorf("failed to load sla policy: %w", err)
+		}
+	}
+
+	var taxonomy appin.StatusTaxonomy
+	if name := os.Getenv("STATUS_TAXONOMY_FILE"); name != "" {
+		taxonomy, err = appin.ReadStatusTaxonomyFile(name)
+		if err != nil {
+			return fmt.Errorf("failed to load status taxonomy: %w", err)
+		}
+	}
+
+	var grouping appin.GroupingSchemes
+	if name := os.Getenv("GROUPING_SCHEMES_FILE"); name != "" {
+		grouping, err = appin.ReadGroupingSchemesFile(name)
+		if err != nil {
+			return fmt.Errorf("failed to load grouping schemes: %w", err)
+		}
+	}
+
+	var weights *appin.ScoreWeights
+	if name := os.Getenv("SCORE_WEIGHTS_FILE"); name != "" {
+		weights, err = appin.ReadScoreWeightsFile(name)
+		if err != nil {
+			return fmt.Errorf("failed to load score weights: %w", err)
+		}
+	}
+
+	var customMetrics appin.CustomMetrics
+	if name := os.Getenv("CUSTOM_METRICS_FILE"); name != "" {
+		customMetrics, err = appin.ReadCustomMetricsFile(name)
+		if err != nil {
+			return fmt.Errorf("failed to load custom metrics: %w", err)
+		}
+	}
+
+	var caColumns *appin.CAFinalColumns
+	if name := os.Getenv("CA_FINAL_COLUMNS_FILE"); name != "" {
+		caColumns, err = appin.ReadCAFinalColumnsFile(name)
+		if err != nil {
+			return fmt.Errorf("failed to load ca final columns: %w", err)
+		}
+	}
+
+	var stages appin.StageConfigs
+	if name := os.Getenv("STAGES_FILE"); name != "" {
+		stages, err = appin.ReadStageConfigsFile(name)
+		if err != nil {
+			return fmt.Errorf("failed to load stages: %w", err)
+		}
+	}
+
+	executors, err := appin.NewExecutorDirectory(getEnv("EXECUTOR_DIRECTORY_FILE", "executors.json"))
+	if err != nil {
+		return fmt.Errorf("failed to load executor directory: %w", err)
+	}
+
+	loc := time.Local
+	if name := os.Getenv("TIMEZONE"); name != "" {
+		loc, err = time.LoadLocation(name)
+		if err != nil {
+			return fmt.Errorf("failed to load timezone: %w", err)
+		}
+	}
+
 	appInSvc, err := appin.NewService(ctx, &appin.Config{
-		Zlog:          zlog,
-		TenantID:      os.Getenv("TENANT_ID"),
-		ClientID:      os.Getenv("CLIENT_ID"),
-		Secret:        os.Getenv("CLIENT_SECRET"),
-		SiteID:        os.Getenv("SITE_ID"),
-		ListID:        os.Getenv("LIST_ID"),
-		CAFinalListID: os.Getenv("CA_FINAL_LIST_ID"),
-		Scopes:        []string{},
+		Zlog:            zlog,
+		TenantID:        os.Getenv("TENANT_ID"),
+		ClientID:        os.Getenv("CLIENT_ID"),
+		Secret:          os.Getenv("CLIENT_SECRET"),
+		SiteID:          os.Getenv("SITE_ID"),
+		ListID:          os.Getenv("LIST_ID"),
+		CAFinalListID:   os.Getenv("CA_FINAL_LIST_ID"),
+		Scopes:          []string{},
+		SLAPolicy:       slaPolicy,
+		Location:        loc,
+		StatusTaxonomy:  taxonomy,
+		GroupingSchemes: grouping,
+		ScoreWeights:    weights,
+		CustomMetrics:   customMetrics,
+		CAFinalColumns:  caColumns,
+		Stages:          stages,
+		Executors:       executors,
 	})
 	if err != nil {
 		return fmt.Errorf("failed to create appin service: %w", err)
 	}
 	zlog.Info("AppIn service initialized")
 
+	alertConfig := new(alert.Config)
+	if name := os.Getenv("ALERT_CONFIG_FILE"); name != "" {
+		alertConfig, err = alert.ReadConfigFile(name)
+		if err != nil {
+			return fmt.Errorf("failed to load alert config: %w", err)
+		}
+	}
+
+	alerts := alert.NewEngine(appInSvc, alertConfig, zlog)
+	go alerts.Run(ctx)
+	zlog.Info("Alert engine started", zap.Int("rules", len(alertConfig.Rules)))
+
+	viewStore, err := view.NewFileStore(getEnv("VIEWS_FILE", "views.json"))
+	if err != nil {
+		return fmt.Errorf("failed to load views: %w", err)
+	}
+
+	views, err := view.NewService(appInSvc, viewStore, zlog)
+	if err != nil {
+		return fmt.Errorf("failed to create view service: %w", err)
+	}
+	zlog.Info("View service initialized")
+
 	e := echo.New()
 	e.HideBanner = true
 	e.HTTPErrorHandler = httpErr
 	e.Use(httpLogger(zlog))
 	e.Use(stdMws()...)
 
-	serve := must(server.NewServer(appInSvc))
+	var admins []string
+	if v := os.Getenv("ADMIN_USERS"); v != "" {
+		admins = strings.Split(v, ",")
+	}
+
+	userSecret := os.Getenv("USER_SIGNING_SECRET")
+	if userSecret == "" {
+		zlog.Warn("USER_SIGNING_SECRET is not set, requests that need a user are rejected")
+	}
+
+	serve := must(server.NewServer(appInSvc, alerts, views, executors, server.NewUserVerifier(userSecret), admins))
 	if err := serve.Install(e); err != nil {
 		return fmt.Errorf("failed to install server: %w", err)
 	}
@@ -0,0 +1,339 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/10664kls/app-in-performance-api/internal/appin"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	rpcstatus "google.golang.org/grpc/status"
+)
+
+// State is the state of an alert.
+type State string
+
+const (
+	// StateInactive is an alert whose condition does not hold and never fired.
+	StateInactive State = "inactive"
+
+	// StatePending is an alert whose condition holds for less than the For of its rule.
+	StatePending State = "pending"
+
+	// StateFiring is an alert whose condition holds.
+	StateFiring State = "firing"
+
+	// StateResolved is an alert that fired and whose condition no longer holds.
+	StateResolved State = "resolved"
+)
+
+// Alert is the state of a rule.
+type Alert struct {
+	RuleID    string   `json:"ruleId"`
+	Name      string   `json:"name"`
+	Severity  Severity `json:"severity"`
+	Metric    string   `json:"metric"`
+	Operator  Operator `json:"operator"`
+	Threshold float64  `json:"threshold"`
+	State     State    `json:"state"`
+
+	// Value is the value of the metric at the last evaluation. Nil if the evaluation failed.
+	Value *float64 `json:"value"`
+
+	// Error is why the last evaluation failed.
+	Error string `json:"error,omitempty"`
+
+	// ActiveSince is since when the condition holds. Nil if it does not.
+	ActiveSince *time.Time `json:"activeSince"`
+
+	FiredAt         *time.Time `json:"firedAt"`
+	ResolvedAt      *time.Time `json:"resolvedAt"`
+	NotifiedAt      *time.Time `json:"notifiedAt"`
+	LastEvaluatedAt *time.Time `json:"lastEvaluatedAt"`
+
+	// Silence is the silence of the alert. Nil if not silenced.
+	Silence *Silence `json:"silence"`
+}
+
+// Silence stops the notifications of an alert until a time. Its state is still tracked.
+type Silence struct {
+	Until  time.Time `json:"until"`
+	Reason string    `json:"reason"`
+}
+
+func (a *Alert) silenced(now time.Time) bool {
+	return a.Silence != nil && now.Before(a.Silence.Until)
+}
+
+func (a *Alert) clone() *Alert {
+	c := *a
+	return &c
+}
+
+// OverviewGetter returns the overview of a query.
+type OverviewGetter interface {
+	GetOverview(ctx context.Context, q *appin.Query) (*appin.Overview, error)
+}
+
+// Engine evaluates alert rules on a schedule and notifies the sinks when an alert fires or resolves.
+type Engine struct {
+	overviews OverviewGetter
+	zlog      *zap.Logger
+	config    *Config
+	sinks     []Sink
+
+	mu     sync.Mutex
+	alerts map[string]*Alert
+}
+
+// NewEngine returns an engine of the config. Notifications are always logged.
+func NewEngine(overviews OverviewGetter, config *Config, zlog *zap.Logger) *Engine {
+	if config == nil {
+		config = new(Config)
+	}
+
+	sinks := []Sink{&LogSink{Zlog: zlog}}
+	for _, c := range config.Sinks {
+		if c.Type != SinkLog {
+			sinks = append(sinks, newSink(c, zlog))
+		}
+	}
+
+	alerts := make(map[string]*Alert, len(config.Rules))
+	for _, r := range config.Rules {
+		alerts[r.ID] = &Alert{
+			RuleID:    r.ID,
+			Name:      r.Name,
+			Severity:  r.severity(),
+			Metric:    r.Metric,
+			Operator:  r.Operator,
+			Threshold: r.Threshold,
+			State:     StateInactive,
+		}
+	}
+
+	return &Engine{
+		overviews: overviews,
+		zlog:      zlog,
+		config:    config,
+		sinks:     sinks,
+		alerts:    alerts,
+	}
+}
+
+// Run evaluates the rules every interval until the context is done.
+func (e *Engine) Run(ctx context.Context) {
+	if len(e.config.Rules) == 0 {
+		return
+	}
+
+	interval := time.Duration(e.config.Interval)
+	if interval == 0 {
+		interval = DefaultInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		e.Evaluate(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Evaluate evaluates every rule once.
+// Rules with the same query share one overview.
+func (e *Engine) Evaluate(ctx context.Context) {
+	now := time.Now()
+	docs := make(map[string]any)
+	errs := make(map[string]error)
+
+	for _, r := range e.config.Rules {
+		q := r.query(now)
+		key, _ := json.Marshal(q)
+
+		doc, ok := docs[string(key)]
+		err := errs[string(key)]
+		if !ok && err == nil {
+			doc, err = e.overview(ctx, q)
+			docs[string(key)], errs[string(key)] = doc, err
+		}
+
+		var value *float64
+		if err == nil {
+			var v float64
+			if v, err = lookup(doc, r.Metric); err == nil {
+				value = &v
+			}
+		}
+		if err != nil {
+			e.zlog.Error("failed to evaluate alert rule", zap.String("rule", r.ID), zap.Error(err))
+		}
+
+		for _, n := range e.transition(r, value, err, now) {
+			e.notify(ctx, n)
+		}
+	}
+}
+
+// overview returns the overview of the query as a JSON document.
+func (e *Engine) overview(ctx context.Context, q *appin.Query) (any, error) {
+	o, err := e.overviews.GetOverview(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	byt, err := json.Marshal(o)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc any
+	return doc, json.Unmarshal(byt, &doc)
+}
+
+// transition moves the alert of the rule to its next state and returns the notifications to send.
+// A failed evaluation keeps the state so a flaky data source does not flap alerts.
+func (e *Engine) transition(r *Rule, value *float64, err error, now time.Time) []*Notification {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	a := e.alerts[r.ID]
+	a.LastEvaluatedAt = &now
+	a.Value = value
+	a.Error = ""
+	if err != nil {
+		a.Error = err.Error()
+		return nil
+	}
+
+	ns := make([]*Notification, 0)
+	notify := func(status State, repeat bool) {
+		if a.silenced(now) {
+			return
+		}
+		a.NotifiedAt = &now
+		ns = append(ns, &Notification{Status: status, Repeat: repeat, Alert: a.clone(), At: now})
+	}
+
+	if !r.Operator.compare(*value, r.Threshold) {
+		a.ActiveSince = nil
+		if a.State == StateFiring {
+			a.State = StateResolved
+			a.ResolvedAt = &now
+			notify(StateResolved, false)
+		} else if a.State == StatePending {
+			a.State = StateInactive
+		}
+		return ns
+	}
+
+	if a.ActiveSince == nil {
+		a.ActiveSince = &now
+	}
+
+	switch a.State {
+	case StateFiring:
+		repeat := time.Duration(e.config.RepeatInterval)
+		if repeat > 0 && (a.NotifiedAt == nil || now.Sub(*a.NotifiedAt) >= repeat) {
+			notify(StateFiring, true)
+		}
+
+	default:
+		if now.Sub(*a.ActiveSince) < time.Duration(r.For) {
+			a.State = StatePending
+			return ns
+		}
+
+		a.State = StateFiring
+		a.FiredAt = &now
+		a.ResolvedAt = nil
+		notify(StateFiring, false)
+	}
+
+	return ns
+}
+
+// notify sends the notification to every sink, each within notifyTimeout,
+// so a sink that hangs does not stop the evaluation of the rules.
+func (e *Engine) notify(ctx context.Context, n *Notification) {
+	for _, s := range e.sinks {
+		sctx, cancel := context.WithTimeout(ctx, notifyTimeout)
+		if err := s.Notify(sctx, n); err != nil {
+			e.zlog.Error("failed to notify alert", zap.String("rule", n.Alert.RuleID), zap.Error(err))
+		}
+		cancel()
+	}
+}
+
+type ListAlertsResult struct {
+	Alerts []*Alert `json:"alerts"`
+}
+
+// ListAlerts lists the alert of every rule.
+func (e *Engine) ListAlerts(_ context.Context) *ListAlertsResult {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	as := make([]*Alert, 0, len(e.alerts))
+	for _, a := range e.alerts {
+		as = append(as, a.clone())
+	}
+	sort.Slice(as, func(i, j int) bool {
+		return as[i].RuleID < as[j].RuleID
+	})
+
+	return &ListAlertsResult{
+		Alerts: as,
+	}
+}
+
+type SilenceRequest struct {
+	// Duration is how long the alert is silenced for. ex: "2h"
+	Duration appin.Duration `json:"duration"`
+	Reason   string         `json:"reason"`
+}
+
+// SilenceAlert silences the notifications of the alert of the rule.
+func (e *Engine) SilenceAlert(_ context.Context, ruleID string, req *SilenceRequest) (*Alert, error) {
+	if req.Duration <= 0 {
+		return nil, rpcstatus.Error(codes.InvalidArgument, "duration must be greater than zero.")
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	a, ok := e.alerts[ruleID]
+	if !ok {
+		return nil, rpcstatus.Error(codes.NotFound, "Alert not found.")
+	}
+
+	a.Silence = &Silence{
+		Until:  time.Now().Add(time.Duration(req.Duration)),
+		Reason: req.Reason,
+	}
+
+	return a.clone(), nil
+}
+
+// UnsilenceAlert removes the silence of the alert of the rule.
+func (e *Engine) UnsilenceAlert(_ context.Context, ruleID string) (*Alert, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	a, ok := e.alerts[ruleID]
+	if !ok {
+		return nil, rpcstatus.Error(codes.NotFound, "Alert not found.")
+	}
+
+	a.Silence = nil
+	return a.clone(), nil
+}
@@ -0,0 +1,144 @@
+package alert
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/10664kls/app-in-performance-api/internal/appin"
+	"go.uber.org/zap"
+)
+
+func TestEngineTransition(t *testing.T) {
+	type step struct {
+		at     time.Duration
+		value  float64
+		err    error
+		want   State
+		notify []string
+	}
+
+	start := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	failed := errors.New("overview failed")
+
+	tests := []struct {
+		name    string
+		forDur  time.Duration
+		repeat  time.Duration
+		silence time.Duration
+		steps   []step
+	}{
+		{
+			name: "fires at once without for",
+			steps: []step{
+				{at: 0, value: 5, want: StateInactive},
+				{at: time.Minute, value: 20, want: StateFiring, notify: []string{"firing"}},
+				{at: 2 * time.Minute, value: 25, want: StateFiring},
+			},
+		},
+		{
+			name:   "pending until for has passed",
+			forDur: 10 * time.Minute,
+			steps: []step{
+				{at: 0, value: 20, want: StatePending},
+				{at: 5 * time.Minute, value: 20, want: StatePending},
+				{at: 10 * time.Minute, value: 20, want: StateFiring, notify: []string{"firing"}},
+			},
+		},
+		{
+			name:   "pending goes back to inactive without notifying",
+			forDur: 10 * time.Minute,
+			steps: []step{
+				{at: 0, value: 20, want: StatePending},
+				{at: 5 * time.Minute, value: 5, want: StateInactive},
+				{at: 12 * time.Minute, value: 20, want: StatePending},
+				{at: 22 * time.Minute, value: 20, want: StateFiring, notify: []string{"firing"}},
+			},
+		},
+		{
+			name: "firing resolves and fires again",
+			steps: []step{
+				{at: 0, value: 20, want: StateFiring, notify: []string{"firing"}},
+				{at: time.Minute, value: 5, want: StateResolved, notify: []string{"resolved"}},
+				{at: 2 * time.Minute, value: 5, want: StateResolved},
+				{at: 3 * time.Minute, value: 20, want: StateFiring, notify: []string{"firing"}},
+			},
+		},
+		{
+			name:   "repeats while firing",
+			repeat: time.Hour,
+			steps: []step{
+				{at: 0, value: 20, want: StateFiring, notify: []string{"firing"}},
+				{at: 30 * time.Minute, value: 20, want: StateFiring},
+				{at: time.Hour, value: 20, want: StateFiring, notify: []string{"firing repeat"}},
+				{at: 90 * time.Minute, value: 20, want: StateFiring},
+				{at: 2 * time.Hour, value: 20, want: StateFiring, notify: []string{"firing repeat"}},
+			},
+		},
+		{
+			name: "failed evaluation keeps the state",
+			steps: []step{
+				{at: 0, value: 20, want: StateFiring, notify: []string{"firing"}},
+				{at: time.Minute, err: failed, want: StateFiring},
+				{at: 2 * time.Minute, value: 20, want: StateFiring},
+			},
+		},
+		{
+			name:    "silenced alert changes state without notifying",
+			silence: time.Hour,
+			repeat:  10 * time.Minute,
+			steps: []step{
+				{at: 0, value: 20, want: StateFiring},
+				{at: 20 * time.Minute, value: 20, want: StateFiring},
+				{at: 30 * time.Minute, value: 5, want: StateResolved},
+				{at: 40 * time.Minute, value: 20, want: StateFiring},
+				{at: 2 * time.Hour, value: 20, want: StateFiring, notify: []string{"firing repeat"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Rule{
+				ID:        "backlog",
+				Metric:    "conversion.total",
+				Operator:  OperatorGreater,
+				Threshold: 10,
+				For:       appin.Duration(tt.forDur),
+			}
+			e := NewEngine(nil, &Config{
+				RepeatInterval: appin.Duration(tt.repeat),
+				Rules:          []*Rule{r},
+			}, zap.NewNop())
+			if tt.silence > 0 {
+				e.alerts[r.ID].Silence = &Silence{Until: start.Add(tt.silence)}
+			}
+
+			for i, s := range tt.steps {
+				var value *float64
+				if s.err == nil {
+					value = &s.value
+				}
+
+				ns := e.transition(r, value, s.err, start.Add(s.at))
+
+				got := make([]string, 0, len(ns))
+				for _, n := range ns {
+					status := string(n.Status)
+					if n.Repeat {
+						status += " repeat"
+					}
+					got = append(got, status)
+				}
+
+				if state := e.alerts[r.ID].State; state != s.want {
+					t.Errorf("step %d: state = %s, want %s", i, state, s.want)
+				}
+				if !slices.Equal(got, s.notify) {
+					t.Errorf("step %d: notifications = %q, want %q", i, got, s.notify)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,304 @@
+package alert
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/10664kls/app-in-performance-api/internal/appin"
+)
+
+// DefaultInterval is how often rules are evaluated when none is configured.
+const DefaultInterval = 5 * time.Minute
+
+// Config is the alert rules and where their notifications are sent.
+type Config struct {
+	// Interval is how often rules are evaluated. DefaultInterval if zero.
+	Interval appin.Duration `json:"interval"`
+
+	// RepeatInterval is how often a firing alert is notified again. Never if zero.
+	RepeatInterval appin.Duration `json:"repeatInterval"`
+
+	Rules []*Rule `json:"rules"`
+
+	// Sinks is where notifications are sent. Notifications are always logged.
+	Sinks []*SinkConfig `json:"sinks"`
+}
+
+// Operator compares the value of a metric with the threshold of a rule.
+type Operator string
+
+const (
+	OperatorGreater      Operator = ">"
+	OperatorGreaterEqual Operator = ">="
+	OperatorLess         Operator = "<"
+	OperatorLessEqual    Operator = "<="
+	OperatorEqual        Operator = "=="
+	OperatorNotEqual     Operator = "!="
+)
+
+func (o Operator) compare(v, threshold float64) bool {
+	switch o {
+	case OperatorGreater:
+		return v > threshold
+	case OperatorGreaterEqual:
+		return v >= threshold
+	case OperatorLess:
+		return v < threshold
+	case OperatorLessEqual:
+		return v <= threshold
+	case OperatorEqual:
+		return v == threshold
+	case OperatorNotEqual:
+		return v != threshold
+	default:
+		return false
+	}
+}
+
+// Severity is how urgent an alert is.
+type Severity string
+
+const (
+	SeverityInfo     Severity = "info"
+	SeverityWarning  Severity = "warning"
+	SeverityCritical Severity = "critical"
+)
+
+// Rule is a condition over a metric of the overview.
+//
+// ex: more than 10 Sale Auto App-In need attention
+//
+//	{
+//	  "id": "saleAutoNeedAttention",
+//	  "query": {"product": "Sale Auto"},
+//	  "metric": "conversion.needAttention",
+//	  "operator": ">",
+//	  "threshold": 10
+//	}
+//
+// ex: more than 3 CA Final pending over 5h
+//
+//	{
+//	  "id": "caFinalPendingOver5h",
+//	  "metric": "caFinalOverview.timeIntervalsByPending[5h+].total",
+//	  "operator": ">",
+//	  "threshold": 3
+//	}
+type Rule struct {
+	// ID is the unique ID of the rule. Alerts are deduplicated by it.
+	ID string `json:"id"`
+
+	// Name is the display name of the rule.
+	Name string `json:"name"`
+
+	// Severity is how urgent the alert of the rule is. SeverityWarning if empty.
+	Severity Severity `json:"severity"`
+
+	// Query is the query of the overview the rule is evaluated on.
+	Query *appin.Query `json:"query"`
+
+	// Window sets the created after of the query to the window before each evaluation.
+	Window appin.Duration `json:"window"`
+
+	// Metric is the JSON path of the metric in the overview. See the metric catalog.
+	// An array element is selected by its title, name or display name in brackets. ex: "productMetrics[Sale Auto].total"
+	// Durations are in nanoseconds.
+	Metric string `json:"metric"`
+
+	Operator  Operator `json:"operator"`
+	Threshold float64  `json:"threshold"`
+
+	// For is how long the condition must hold before the alert fires. Fires at once if zero.
+	For appin.Duration `json:"for"`
+}
+
+// ReadConfigFile reads the alert config from a JSON file.
+func ReadConfigFile(name string) (*Config, error) {
+	byt, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read alert config: %w", err)
+	}
+
+	c := new(Config)
+	if err := json.Unmarshal(byt, c); err != nil {
+		return nil, fmt.Errorf("failed to parse alert config: %w", err)
+	}
+
+	return c, c.Validate()
+}
+
+func (c *Config) Validate() error {
+	if c.Interval < 0 || c.RepeatInterval < 0 {
+		return fmt.Errorf("alert intervals must not be negative")
+	}
+
+	ids := make(map[string]bool, len(c.Rules))
+	for _, r := range c.Rules {
+		if err := r.Validate(); err != nil {
+			return fmt.Errorf("alert rule %q: %w", r.ID, err)
+		}
+		if ids[r.ID] {
+			return fmt.Errorf("alert rule %q is duplicated", r.ID)
+		}
+		ids[r.ID] = true
+	}
+
+	for _, s := range c.Sinks {
+		if err := s.Validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *Rule) Validate() error {
+	if r.ID == "" {
+		return fmt.Errorf("id is empty")
+	}
+	if _, err := parsePath(r.Metric); err != nil {
+		return err
+	}
+
+	switch r.Operator {
+	case OperatorGreater, OperatorGreaterEqual, OperatorLess, OperatorLessEqual, OperatorEqual, OperatorNotEqual:
+	default:
+		return fmt.Errorf("unknown operator %q", r.Operator)
+	}
+
+	switch r.Severity {
+	case "", SeverityInfo, SeverityWarning, SeverityCritical:
+	default:
+		return fmt.Errorf("unknown severity %q", r.Severity)
+	}
+
+	if r.Window < 0 || r.For < 0 {
+		return fmt.Errorf("window and for must not be negative")
+	}
+
+	return nil
+}
+
+func (r *Rule) severity() Severity {
+	if r.Severity == "" {
+		return SeverityWarning
+	}
+	return r.Severity
+}
+
+// query returns the query of the overview at now.
+func (r *Rule) query(now time.Time) *appin.Query {
+	q := new(appin.Query)
+	if r.Query != nil {
+		*q = *r.Query
+	}
+	if r.Window > 0 {
+		q.CreatedAfter = now.Add(-time.Duration(r.Window))
+		q.CreatedBefore = time.Time{}
+	}
+
+	return q
+}
+
+// pathSegment is a segment of a metric path. Key is set if the segment selects an array element.
+type pathSegment struct {
+	field string
+	key   string
+	isKey bool
+}
+
+func parsePath(path string) ([]*pathSegment, error) {
+	if strings.TrimSpace(path) == "" {
+		return nil, fmt.Errorf("metric is empty")
+	}
+
+	segments := make([]*pathSegment, 0)
+	for part := range strings.SplitSeq(path, ".") {
+		s := &pathSegment{field: part}
+		if i := strings.IndexByte(part, '['); i >= 0 {
+			if !strings.HasSuffix(part, "]") {
+				return nil, fmt.Errorf("metric %q has an unclosed selector", path)
+			}
+			s.field, s.key, s.isKey = part[:i], part[i+1:len(part)-1], true
+		}
+		if s.field == "" {
+			return nil, fmt.Errorf("metric %q has an empty segment", path)
+		}
+
+		segments = append(segments, s)
+	}
+
+	return segments, nil
+}
+
+// selectorFields is the fields an array element is selected by.
+var selectorFields = []string{"title", "name", "displayName", "id"}
+
+// lookup returns the value of the metric path in the JSON document of the overview.
+func lookup(doc any, path string) (float64, error) {
+	segments, err := parsePath(path)
+	if err != nil {
+		return 0, err
+	}
+
+	v := doc
+	for _, s := range segments {
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return 0, fmt.Errorf("metric %q: %s is not an object", path, s.field)
+		}
+		if v, ok = obj[s.field]; !ok {
+			return 0, fmt.Errorf("metric %q: %s not found", path, s.field)
+		}
+
+		if s.isKey {
+			if v, ok = selectElement(v, s.key); !ok {
+				return 0, fmt.Errorf("metric %q: %s[%s] not found", path, s.field, s.key)
+			}
+		}
+	}
+
+	switch x := v.(type) {
+	case float64:
+		return x, nil
+	case bool:
+		if x {
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		if f, err := strconv.ParseFloat(x, 64); err == nil {
+			return f, nil
+		}
+		if dur, err := time.ParseDuration(x); err == nil {
+			return float64(dur), nil
+		}
+	}
+
+	return 0, fmt.Errorf("metric %q is not a number", path)
+}
+
+func selectElement(v any, key string) (any, bool) {
+	arr, ok := v.([]any)
+	if !ok {
+		return nil, false
+	}
+
+	for _, e := range arr {
+		obj, ok := e.(map[string]any)
+		if !ok {
+			continue
+		}
+		for _, f := range selectorFields {
+			if s, ok := obj[f].(string); ok && strings.EqualFold(s, key) {
+				return obj, true
+			}
+		}
+	}
+
+	return nil, false
+}
@@ -0,0 +1,277 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// notifyTimeout is the time a sink has to send a notification.
+	notifyTimeout = 30 * time.Second
+
+	// smtpTimeout is the time an SMTP sink has to send a mail if the context has no deadline.
+	smtpTimeout = 30 * time.Second
+)
+
+// Sink sends notifications of alerts.
+type Sink interface {
+	Notify(ctx context.Context, n *Notification) error
+}
+
+// Notification is a change of an alert sent to the sinks.
+type Notification struct {
+	// Status is the state the alert changed to. StateFiring or StateResolved.
+	Status State `json:"status"`
+
+	// Repeat is true if the alert was already notified as firing.
+	Repeat bool `json:"repeat"`
+
+	Alert *Alert    `json:"alert"`
+	At    time.Time `json:"at"`
+}
+
+func (n *Notification) subject() string {
+	return fmt.Sprintf("[%s] %s: %s", strings.ToUpper(string(n.Status)), n.Alert.Severity, n.Alert.Name)
+}
+
+func (n *Notification) text() string {
+	a := n.Alert
+	value := "unknown"
+	if a.Value != nil {
+		value = fmt.Sprint(*a.Value)
+	}
+
+	return fmt.Sprintf("%s\n\nRule: %s\nCondition: %s %s %v\nValue: %s\nAt: %s\n",
+		n.subject(), a.RuleID, a.Metric, a.Operator, a.Threshold, value, n.At.Format(time.RFC3339))
+}
+
+// SinkType is the type of a sink.
+type SinkType string
+
+const (
+	SinkLog     SinkType = "log"
+	SinkWebhook SinkType = "webhook"
+	SinkSMTP    SinkType = "smtp"
+)
+
+// SinkConfig is the config of a sink.
+type SinkConfig struct {
+	Type SinkType `json:"type"`
+
+	// URL is the URL the webhook sink posts notifications to as JSON.
+	URL string `json:"url"`
+
+	// Headers is the headers of the webhook requests.
+	Headers map[string]string `json:"headers"`
+
+	// Addr is the host:port of the SMTP server.
+	Addr string `json:"addr"`
+
+	// Username and Password authenticate to the SMTP server with PLAIN auth. No auth if Username is empty.
+	Username string `json:"username"`
+	Password string `json:"password"`
+
+	From string   `json:"from"`
+	To   []string `json:"to"`
+}
+
+func (c *SinkConfig) Validate() error {
+	switch c.Type {
+	case SinkLog:
+	case SinkWebhook:
+		if c.URL == "" {
+			return fmt.Errorf("webhook sink url is empty")
+		}
+	case SinkSMTP:
+		if c.Addr == "" || c.From == "" || len(c.To) == 0 {
+			return fmt.Errorf("smtp sink needs addr, from and to")
+		}
+	default:
+		return fmt.Errorf("unknown sink type %q", c.Type)
+	}
+
+	return nil
+}
+
+func newSink(c *SinkConfig, zlog *zap.Logger) Sink {
+	switch c.Type {
+	case SinkWebhook:
+		return &WebhookSink{
+			URL:     c.URL,
+			Headers: c.Headers,
+			Client:  &http.Client{Timeout: 10 * time.Second},
+		}
+	case SinkSMTP:
+		return &SMTPSink{
+			Addr:     c.Addr,
+			Username: c.Username,
+			Password: c.Password,
+			From:     c.From,
+			To:       c.To,
+		}
+	default:
+		return &LogSink{Zlog: zlog}
+	}
+}
+
+// LogSink logs notifications.
+type LogSink struct {
+	Zlog *zap.Logger
+}
+
+func (s *LogSink) Notify(_ context.Context, n *Notification) error {
+	fields := []zap.Field{
+		zap.String("rule", n.Alert.RuleID),
+		zap.String("severity", string(n.Alert.Severity)),
+		zap.String("metric", n.Alert.Metric),
+		zap.Float64p("value", n.Alert.Value),
+		zap.Float64("threshold", n.Alert.Threshold),
+		zap.Bool("repeat", n.Repeat),
+	}
+
+	if n.Status == StateFiring {
+		s.Zlog.Warn("alert firing", fields...)
+	} else {
+		s.Zlog.Info("alert resolved", fields...)
+	}
+
+	return nil
+}
+
+// WebhookSink posts notifications as JSON.
+type WebhookSink struct {
+	URL     string
+	Headers map[string]string
+	Client  *http.Client
+}
+
+func (s *WebhookSink) Notify(ctx context.Context, n *Notification) error {
+	byt, err := json.Marshal(n)
+	if err != nil {
+		return fmt.Errorf("failed to marshal notification: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(byt))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range s.Headers {
+		req.Header.Set(k, v)
+	}
+
+	res, err := s.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post webhook: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", res.StatusCode)
+	}
+
+	return nil
+}
+
+// SMTPSink emails notifications.
+type SMTPSink struct {
+	Addr     string
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+// Notify sends the mail within the deadline of ctx, or smtpTimeout if ctx has none.
+// The connection is closed when ctx is done so a hung server cannot block the caller.
+func (s *SMTPSink) Notify(ctx context.Context, n *Notification) error {
+	host, _, err := net.SplitHostPort(s.Addr)
+	if err != nil {
+		return fmt.Errorf("invalid smtp addr: %w", err)
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, smtpTimeout)
+		defer cancel()
+	}
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", s.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(s.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", n.subject())
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(n.text(), "\n", "\r\n"))
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", s.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to dial smtp server: %w", err)
+	}
+	defer conn.Close()
+
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return fmt.Errorf("failed to set smtp deadline: %w", err)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if err := s.send(conn, host, msg.String()); err != nil {
+		return fmt.Errorf("failed to send mail: %w", err)
+	}
+
+	return nil
+}
+
+// send sends the message over the connection like smtp.SendMail.
+func (s *SMTPSink) send(conn net.Conn, host, msg string) error {
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if s.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.Username, s.Password, host)); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(s.From); err != nil {
+		return err
+	}
+	for _, to := range s.To {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte(msg)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
@@ -0,0 +1,233 @@
+package appin
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	rpcstatus "google.golang.org/grpc/status"
+)
+
+// maxBacklogPoints is the maximum number of points of a backlog series.
+const maxBacklogPoints = 2000
+
+// BacklogOverview is the work in progress of every stage over time.
+type BacklogOverview struct {
+	// Interval is the time between two points of the series.
+	Interval Interval `json:"interval"`
+
+	// AppIn is the backlog of App-In.
+	AppIn *Backlog `json:"appIn"`
+
+	// CAFinal is the backlog of CA Final.
+	CAFinal *Backlog `json:"caFinal"`
+}
+
+// Backlog is the work in progress of a stage over time.
+type Backlog struct {
+	// Series is the number of open items at the start of each period.
+	Series []*BacklogPoint `json:"series"`
+
+	// Peak is the highest number of open items at any time in the period.
+	Peak *BacklogPoint `json:"peak"`
+
+	// ByExecutor is the backlog of each executor, the highest peak first.
+	ByExecutor []*BacklogSeries `json:"byExecutor"`
+
+	// ByProduct is the backlog of each product, the highest peak first.
+	ByProduct []*BacklogSeries `json:"byProduct"`
+}
+
+// BacklogPoint is the number of open items at a time.
+type BacklogPoint struct {
+	At   time.Time `json:"at"`
+	Open int64     `json:"open"`
+}
+
+// BacklogSeries is the backlog of a group.
+type BacklogSeries struct {
+	Name   string          `json:"name"`
+	Series []*BacklogPoint `json:"series"`
+	Peak   *BacklogPoint   `json:"peak"`
+}
+
+// wipItem is an item opened at start and closed at end, if closed.
+type wipItem struct {
+	executor string
+	product  string
+	start    time.Time
+	end      *time.Time
+}
+
+// GetBacklog returns the work in progress reconstructed from the created and completed time
+// of the items open at any time of the query period, including the items created before it.
+func (s *Service) GetBacklog(ctx context.Context, q *Query) (*BacklogOverview, error) {
+	interval, err := q.interval()
+	if err != nil {
+		return nil, err
+	}
+
+	after, before := q.period()
+	if after.IsZero() {
+		return nil, rpcstatus.Error(codes.InvalidArgument, "createdAfter is required for the backlog.")
+	}
+
+	points := backlogPoints(after, before, interval, s.loc)
+	if len(points) > maxBacklogPoints {
+		return nil, rpcstatus.Error(codes.InvalidArgument, fmt.Sprintf("The backlog must not have more than %d points. Use a longer interval.", maxBacklogPoints))
+	}
+
+	as, ca, err := s.listOpenStages(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BacklogOverview{
+		Interval: interval,
+		AppIn:    newBacklog(wipItems(records(as)), points, after, before),
+		CAFinal:  newBacklog(wipItems(records(ca)), points, after, before),
+	}, nil
+}
+
+// wipItems returns the records that are pending or have a completed time.
+// Records closed without a completed time were closed at an unknown time and are skipped.
+func wipItems(rs []*Record) []*wipItem {
+	items := make([]*wipItem, 0, len(rs))
+	for _, r := range rs {
+		if !r.pending() && r.CompletedAt == nil {
+			continue
+		}
+
+		items = append(items, &wipItem{
+			executor: r.Executor,
+			product:  r.Product,
+			start:    r.CreatedAt,
+			end:      r.CompletedAt,
+		})
+	}
+
+	return items
+}
+
+// backlogPoints returns the start of each period between after and before.
+func backlogPoints(after, before time.Time, interval Interval, loc *time.Location) []time.Time {
+	points := make([]time.Time, 0)
+	for t := interval.truncate(after, loc); !t.After(before); t = interval.next(t) {
+		points = append(points, t)
+		if len(points) > maxBacklogPoints {
+			break
+		}
+	}
+
+	return points
+}
+
+func newBacklog(items []*wipItem, points []time.Time, start, end time.Time) *Backlog {
+	byExecutor := make(map[string][]*wipItem)
+	byProduct := make(map[string][]*wipItem)
+	for _, it := range items {
+		if it.executor != "" {
+			byExecutor[it.executor] = append(byExecutor[it.executor], it)
+		}
+		if it.product != "" {
+			byProduct[it.product] = append(byProduct[it.product], it)
+		}
+	}
+
+	return &Backlog{
+		Series:     sampleWIP(items, points),
+		Peak:       peakWIP(items, start, end),
+		ByExecutor: newBacklogSeries(byExecutor, points, start, end),
+		ByProduct:  newBacklogSeries(byProduct, points, start, end),
+	}
+}
+
+func newBacklogSeries(groups map[string][]*wipItem, points []time.Time, start, end time.Time) []*BacklogSeries {
+	ss := make([]*BacklogSeries, 0, len(groups))
+	for name, items := range groups {
+		ss = append(ss, &BacklogSeries{
+			Name:   name,
+			Series: sampleWIP(items, points),
+			Peak:   peakWIP(items, start, end),
+		})
+	}
+
+	sort.Slice(ss, func(i, j int) bool {
+		if ss[i].Peak.Open != ss[j].Peak.Open {
+			return ss[i].Peak.Open > ss[j].Peak.Open
+		}
+		return ss[i].Name < ss[j].Name
+	})
+
+	return ss
+}
+
+// wipEvent is an item being opened (+1) or closed (-1).
+type wipEvent struct {
+	at    time.Time
+	delta int64
+}
+
+// wipEvents returns the events of the items sorted by time. Closes come before opens at the same time.
+func wipEvents(items []*wipItem) []wipEvent {
+	events := make([]wipEvent, 0, 2*len(items))
+	for _, it := range items {
+		events = append(events, wipEvent{at: it.start, delta: 1})
+		if it.end != nil && !it.end.Before(it.start) {
+			events = append(events, wipEvent{at: *it.end, delta: -1})
+		}
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		if !events[i].at.Equal(events[j].at) {
+			return events[i].at.Before(events[j].at)
+		}
+		return events[i].delta < events[j].delta
+	})
+
+	return events
+}
+
+// sampleWIP returns the number of open items at each point.
+func sampleWIP(items []*wipItem, points []time.Time) []*BacklogPoint {
+	events := wipEvents(items)
+	series := make([]*BacklogPoint, 0, len(points))
+
+	var open int64
+	i := 0
+	for _, t := range points {
+		for i < len(events) && !events[i].at.After(t) {
+			open += events[i].delta
+			i++
+		}
+		series = append(series, &BacklogPoint{At: t, Open: open})
+	}
+
+	return series
+}
+
+// peakWIP returns the first time the most items were open between start and end.
+// Items opened before start count as open at start.
+func peakWIP(items []*wipItem, start, end time.Time) *BacklogPoint {
+	peak := &BacklogPoint{At: start}
+
+	var open int64
+	for _, e := range wipEvents(items) {
+		if e.at.After(end) {
+			break
+		}
+
+		open += e.delta
+		if e.at.Before(start) {
+			peak.Open = open
+			continue
+		}
+		if open > peak.Open {
+			peak = &BacklogPoint{At: e.at, Open: open}
+		}
+	}
+
+	return peak
+}
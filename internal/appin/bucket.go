@@ -0,0 +1,145 @@
+package appin
+
+import (
+	"net/url"
+	"slices"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	rpcstatus "google.golang.org/grpc/status"
+)
+
+const (
+	// ConvertedBucketParam is the query parameter of the drill-down into a converted time interval.
+	ConvertedBucketParam = "convertedBucket"
+
+	// PendingBucketParam is the query parameter of the drill-down into a pending time interval.
+	PendingBucketParam = "pendingBucket"
+)
+
+// timeIntervalTitles is the buckets of the time intervals, the shortest first.
+// The titles are the drill-down keys so they must not change.
+var timeIntervalTitles = []string{
+	"<30min",
+	"<1h",
+	"<2h",
+	"<3h",
+	"<4h",
+	"<5h",
+	"5h+",
+}
+
+// timeIntervalTitle returns the bucket of the duration.
+func timeIntervalTitle(d time.Duration) string {
+	switch {
+	case d < 30*time.Minute:
+		return "<30min"
+	case d < time.Hour:
+		return "<1h"
+	case d < 2*time.Hour:
+		return "<2h"
+	case d < 3*time.Hour:
+		return "<3h"
+	case d < 4*time.Hour:
+		return "<4h"
+	case d < 5*time.Hour:
+		return "<5h"
+	default:
+		return "5h+"
+	}
+}
+
+// newTimeIntervals counts the durations by bucket.
+// param is the query parameter that drills down into a bucket,
+// and filters the other query parameters of the drill-down, such as the executor of the durations.
+func newTimeIntervals(param string, filters url.Values, durations []time.Duration) []*TimeInterval {
+	intervals := make(map[string]int64)
+	for _, d := range durations {
+		intervals[timeIntervalTitle(d)]++
+	}
+
+	ts := make([]*TimeInterval, 0, len(timeIntervalTitles))
+	for _, t := range timeIntervalTitles {
+		drillDown := url.Values{param: {t}}
+		for k, v := range filters {
+			drillDown[k] = v
+		}
+
+		ts = append(ts, &TimeInterval{
+			Title:     t,
+			Total:     intervals[t],
+			Key:       t,
+			DrillDown: drillDown.Encode(),
+		})
+	}
+
+	return ts
+}
+
+// buckets returns the drill-down buckets of the query.
+func (q *Query) buckets() (converted string, pending string, err error) {
+	if q.ConvertedBucket != "" && !slices.Contains(timeIntervalTitles, q.ConvertedBucket) {
+		return "", "", rpcstatus.Error(codes.InvalidArgument, "convertedBucket must be one of <30min, <1h, <2h, <3h, <4h, <5h or 5h+.")
+	}
+	if q.PendingBucket != "" && !slices.Contains(timeIntervalTitles, q.PendingBucket) {
+		return "", "", rpcstatus.Error(codes.InvalidArgument, "pendingBucket must be one of <30min, <1h, <2h, <3h, <4h, <5h or 5h+.")
+	}
+	if q.ConvertedBucket != "" && q.PendingBucket != "" {
+		return "", "", rpcstatus.Error(codes.InvalidArgument, "convertedBucket and pendingBucket cannot be used together.")
+	}
+
+	return q.ConvertedBucket, q.PendingBucket, nil
+}
+
+// inBucket reports whether an item is counted in the bucket of the drill-down.
+// Every item is if no bucket is requested.
+func inBucket(converted, pending string, isConverted, isPending bool, age time.Duration) bool {
+	switch {
+	case converted != "":
+		return isConverted && timeIntervalTitle(age) == converted
+	case pending != "":
+		return isPending && timeIntervalTitle(age) == pending
+	default:
+		return true
+	}
+}
+
+// appInsInBucket returns the App-In of the drill-down bucket of the query.
+func appInsInBucket(as []*AppIn, q *Query) ([]*AppIn, error) {
+	converted, pending, err := q.buckets()
+	if err != nil || (converted == "" && pending == "") {
+		return as, err
+	}
+
+	items := make([]*AppIn, 0)
+	for _, a := range as {
+		if inBucket(converted, pending, a.converted(), a.pending(), a.Age) {
+			items = append(items, a)
+		}
+	}
+	return items, nil
+}
+
+// caFinalsInBucket returns the CA Final of the drill-down bucket of the query.
+func caFinalsInBucket(cs []*CAFinal, q *Query) ([]*CAFinal, error) {
+	converted, pending, err := q.buckets()
+	if err != nil || (converted == "" && pending == "") {
+		return cs, err
+	}
+
+	items := make([]*CAFinal, 0)
+	for _, c := range cs {
+		if inBucket(converted, pending, c.converted(), c.pending(), c.Age) {
+			items = append(items, c)
+		}
+	}
+	return items, nil
+}
+
+// age returns the time from creation to completion, or to now if not completed.
+func age(createdAt time.Time, completedAt *time.Time) time.Duration {
+	if completedAt == nil {
+		return time.Since(createdAt)
+	}
+	return completedAt.Sub(createdAt)
+}
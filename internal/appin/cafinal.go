@@ -0,0 +1,259 @@
+package appin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// CAFinalColumns is the columns of the CA Final list that hold the product, customer type and reason.
+// The names are the internal names of the SharePoint columns. An empty name is not fetched.
+//
+// A CA Final without a product or customer type column takes them from the App-In of the same loan number.
+type CAFinalColumns struct {
+	Product      string `json:"product"`
+	CustomerType string `json:"customerType"`
+
+	// Reason is why the CA Final was not converted. The status is used if empty.
+	Reason string `json:"reason"`
+}
+
+// ReadCAFinalColumnsFile reads CA Final columns from a JSON file.
+func ReadCAFinalColumnsFile(name string) (*CAFinalColumns, error) {
+	byt, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ca final columns: %w", err)
+	}
+
+	c := new(CAFinalColumns)
+	if err := json.Unmarshal(byt, c); err != nil {
+		return nil, fmt.Errorf("failed to parse ca final columns: %w", err)
+	}
+
+	return c, nil
+}
+
+// names returns the configured column names.
+func (c *CAFinalColumns) names() []string {
+	names := make([]string, 0, 3)
+	for _, n := range []string{c.Product, c.CustomerType, c.Reason} {
+		if n != "" {
+			names = append(names, n)
+		}
+	}
+
+	return names
+}
+
+// joined reports whether the product and customer type come from App-In.
+func (c *CAFinalColumns) joined() bool {
+	return c.Product == "" || c.CustomerType == ""
+}
+
+// setColumns sets the configured columns of the CA Final from its raw fields.
+func (c *CAFinal) setColumns(cols *CAFinalColumns, fields map[string]any) {
+	c.Product = fieldString(fields, cols.Product)
+	c.Type = fieldString(fields, cols.CustomerType)
+	c.Reason = fieldString(fields, cols.Reason)
+}
+
+// joinAppIns sets the missing product and customer type of each CA Final from the App-In of the same loan number.
+func joinAppIns(ca []*CAFinal, as []*AppIn) {
+	index := make(map[string]*AppIn, len(as))
+	for _, a := range as {
+		if key := loanKey(a.Number); key != "" {
+			index[key] = a
+		}
+	}
+
+	for _, c := range ca {
+		a, ok := index[loanKey(c.Number)]
+		if !ok {
+			continue
+		}
+		if c.Product == "" {
+			c.Product = a.Product
+		}
+		if c.Type == "" {
+			c.Type = a.Type
+		}
+	}
+}
+
+// caFinalsOfProduct returns the CA Final of the product, matched case-insensitively. Every CA Final if empty.
+// CA Final of an unknown product are left out.
+func caFinalsOfProduct(ca []*CAFinal, product string) []*CAFinal {
+	if product == "" {
+		return ca
+	}
+
+	cs := make([]*CAFinal, 0, len(ca))
+	for _, c := range ca {
+		if strings.EqualFold(c.Product, product) {
+			cs = append(cs, c)
+		}
+	}
+
+	return cs
+}
+
+// maxLoansPerFilter is the number of loan numbers looked up in one request, to keep the filter short.
+const maxLoansPerFilter = 40
+
+// joinAppInsByLoan sets the missing product and customer type of each CA Final from the App-In of the same loan number.
+// The App-In listed are used first, the App-In of the other loan numbers are looked up whenever they were created.
+func (s *Service) joinAppInsByLoan(ctx context.Context, ca []*CAFinal, as []*AppIn) error {
+	joinAppIns(ca, as)
+
+	listed := make(map[string]bool, len(as))
+	for _, a := range as {
+		listed[loanKey(a.Number)] = true
+	}
+
+	numbers := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, c := range ca {
+		key := loanKey(c.Number)
+		if key == "" || listed[key] || seen[key] || (c.Product != "" && c.Type != "") {
+			continue
+		}
+		seen[key] = true
+		numbers = append(numbers, strings.TrimSpace(c.Number))
+	}
+
+	found, err := s.listAppInsByLoans(ctx, numbers)
+	if err != nil {
+		return err
+	}
+	joinAppIns(ca, found)
+
+	return nil
+}
+
+// listAppInsByLoans lists the App-In of the loan numbers, whenever they were created.
+func (s *Service) listAppInsByLoans(ctx context.Context, numbers []string) ([]*AppIn, error) {
+	return listByLoans(ctx, numbers, "LOFacility", s.listAppInsByFilter)
+}
+
+// listCAFinalsByLoans lists the CA Final of the loan numbers, whenever they were assigned.
+func (s *Service) listCAFinalsByLoans(ctx context.Context, numbers []string) ([]*CAFinal, error) {
+	return listByLoans(ctx, numbers, "FL", s.listCAFinalsByFilter)
+}
+
+// listByLoans lists the items whose loan number column is one of the numbers, a few numbers per request.
+func listByLoans[T any](ctx context.Context, numbers []string, column string, list func(context.Context, string) ([]T, error)) ([]T, error) {
+	var mu sync.Mutex
+	items := make([]T, 0, len(numbers))
+
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(4)
+	for batch := range slices.Chunk(numbers, maxLoansPerFilter) {
+		g.Go(func() error {
+			filters := make([]string, 0, len(batch))
+			for _, n := range batch {
+				filters = append(filters, fmt.Sprintf("fields/%s eq '%s'", column, strings.ReplaceAll(n, "'", "''")))
+			}
+
+			found, err := list(ctx, strings.Join(filters, " or "))
+			if err != nil {
+				return err
+			}
+
+			mu.Lock()
+			items = append(items, found...)
+			mu.Unlock()
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// listCAFinalsWithProducts lists the CA Final matching the query with their product and customer type.
+// When they come from App-In, only the App-In of the loan numbers of the CA Final are looked up.
+func (s *Service) listCAFinalsWithProducts(ctx context.Context, q *Query) ([]*CAFinal, error) {
+	ca, err := s.listCAFinals(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.caColumns.joined() {
+		if err := s.joinAppInsByLoan(ctx, ca, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return caFinalsOfProduct(ca, q.Product), nil
+}
+
+// Rejection is the number of items of a non-converted outcome and reason.
+type Rejection struct {
+	Outcome Outcome `json:"outcome"`
+
+	// Reason is the reason column of the items, or their status if no reason column is configured.
+	Reason string `json:"reason"`
+
+	Total int64 `json:"total"`
+
+	// Share is the percentage of the items of the outcome with the reason.
+	Share float32 `json:"share"`
+}
+
+// newRejections counts the rejected, cancelled and returned records by reason, the most frequent first.
+func newRejections(cs []*Record) []*Rejection {
+	type key struct {
+		outcome Outcome
+		reason  string
+	}
+
+	counts := make(map[key]int64)
+	outcomes := make(map[Outcome]int64)
+	for _, c := range cs {
+		switch c.Outcome {
+		case OutcomeRejected, OutcomeCancelled, OutcomeReturned:
+		default:
+			continue
+		}
+
+		reason := c.Reason
+		if reason == "" {
+			reason = strings.TrimSpace(c.Status)
+		}
+
+		counts[key{c.Outcome, reason}]++
+		outcomes[c.Outcome]++
+	}
+
+	rs := make([]*Rejection, 0, len(counts))
+	for k, n := range counts {
+		rs = append(rs, &Rejection{
+			Outcome: k.outcome,
+			Reason:  k.reason,
+			Total:   n,
+			Share:   rate(n, outcomes[k.outcome]),
+		})
+	}
+
+	sort.Slice(rs, func(i, j int) bool {
+		if rs[i].Outcome != rs[j].Outcome {
+			return rs[i].Outcome < rs[j].Outcome
+		}
+		if rs[i].Total != rs[j].Total {
+			return rs[i].Total > rs[j].Total
+		}
+		return rs[i].Reason < rs[j].Reason
+	})
+
+	return rs
+}
@@ -0,0 +1,666 @@
+package appin
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// MetricCatalogPath is the path the metric catalog is served at.
+const MetricCatalogPath = "/v1/metrics/catalog"
+
+// Metric is the definition of a metric of the overview.
+type Metric struct {
+	// ID is the unique ID of the metric. ex: "appin.conversion.rate"
+	ID string `json:"id"`
+
+	// Stage is the stage the metric is calculated from. Empty if the metric spans every stage.
+	Stage Stage `json:"stage"`
+
+	// Path is the JSON path of the metric in the overview. ex: "caFinalOverview.conversion.rate"
+	Path string `json:"path"`
+
+	// Description is what the metric means.
+	Description string `json:"description"`
+
+	// Formula is how the metric is calculated.
+	Formula string `json:"formula"`
+
+	// Unit is the unit of the metric.
+	// One of: count, percent, duration (nanoseconds), decimal, number, score, name, histogram, delta, list.
+	Unit string `json:"unit"`
+}
+
+type ListMetricCatalogResult struct {
+	Metrics []*Metric `json:"metrics"`
+}
+
+// ListMetricCatalog lists the definition of every metric of the overview,
+// with the configured stages and custom metrics.
+func (s *Service) ListMetricCatalog(_ context.Context) *ListMetricCatalogResult {
+	return &ListMetricCatalogResult{
+		Metrics: s.catalog,
+	}
+}
+
+// stageMetric is a metric shared by the stages with the formula of each stage.
+// The App-In formula is used by the stages that count rejected items as processed, the CA Final one by the others.
+type stageMetric struct {
+	field       string
+	description string
+	unit        string
+	appIn       string
+	caFinal     string
+
+	// caFinalDescription is the description of CA Final if it differs.
+	caFinalDescription string
+}
+
+func (m *stageMetric) formula(st *StageConfig) string {
+	if st.RejectedIsProcessed {
+		return m.appIn
+	}
+	return m.caFinal
+}
+
+// sharedMetrics is the metrics of every stage.
+// Converted, rejected and pending are the outcomes of the status taxonomy.
+var sharedMetrics = []*stageMetric{
+	{
+		field:       "activeExecutor",
+		description: "Number of executors with at least one item.",
+		unit:        "count",
+		appIn:       "count(distinct executor)",
+		caFinal:     "count(distinct executor)",
+	},
+	{
+		field:       "topPerformer",
+		description: "Executor who converted the most items, whatever the leaderboard is ranked by.",
+		unit:        "name",
+		appIn:       "argmax(executor by converted, then rate, then name)",
+		caFinal:     "argmax(executor by converted, then rate, then name)",
+	},
+	{
+		field:       "conversion.total",
+		description: "Number of items created in the period.",
+		unit:        "count",
+		appIn:       "count(items)",
+		caFinal:     "count(items)",
+
+		caFinalDescription: "Number of items assigned in the period.",
+	},
+	{
+		field:       "conversion.converted",
+		description: "Number of converted items with a completed time.",
+		unit:        "count",
+		appIn:       "count(outcome = converted and completedAt set)",
+		caFinal:     "count(outcome = converted and completedAt set)",
+	},
+	{
+		field:       "conversion.notPassed",
+		description: "Number of rejected items.",
+		unit:        "count",
+		appIn:       "count(outcome = rejected)",
+		caFinal:     "count(outcome = rejected)",
+	},
+	{
+		field:       "conversion.cancelled",
+		description: "Number of cancelled items.",
+		unit:        "count",
+		appIn:       "count(outcome = cancelled)",
+		caFinal:     "count(outcome = cancelled)",
+	},
+	{
+		field:       "conversion.returned",
+		description: "Number of returned items.",
+		unit:        "count",
+		appIn:       "count(outcome = returned)",
+		caFinal:     "count(outcome = returned)",
+	},
+	{
+		field:       "conversion.rate",
+		description: "Share of items processed. Rejected items count as processed in App-In but not in CA Final.",
+		unit:        "percent",
+		appIn:       "(converted + notPassed) / total * 100",
+		caFinal:     "converted / total * 100",
+	},
+	{
+		field:       "conversion.fastest",
+		description: "Number of converted items completed within 30 minutes.",
+		unit:        "count",
+		appIn:       "count(converted and completedAt - createdAt <= 30m)",
+		caFinal:     "count(converted and completedAt - createdAt <= 30m)",
+	},
+	{
+		field:       "conversion.fastestPercent",
+		description: "Share of fastest items. The denominator differs between stages.",
+		unit:        "percent",
+		appIn:       "fastest / (converted + notPassed) * 100",
+		caFinal:     "fastest / converted * 100",
+	},
+	{
+		field:       "conversion.needAttention",
+		description: "Number of pending items older than their SLA target.",
+		unit:        "count",
+		appIn:       "count(pending and now - createdAt > sla target)",
+		caFinal:     "count(pending and now - createdAt > sla target)",
+	},
+	{
+		field:       "conversion.bestTime",
+		description: "Shortest turnaround of a converted item, ignoring turnarounds under a minute.",
+		unit:        "duration",
+		appIn:       "min(completedAt - createdAt) of converted where >= 1m",
+		caFinal:     "min(completedAt - createdAt) of converted where >= 1m",
+	},
+	{
+		field:       "conversion.averageTime",
+		description: "Average turnaround. In App-In the turnaround of conversions is divided by conversions and rejections.",
+		unit:        "duration",
+		appIn:       "sum(completedAt - createdAt of converted) / (converted + notPassed)",
+		caFinal:     "sum(completedAt - createdAt of converted) / converted",
+	},
+	{
+		field:       "timeIntervalsByConverted",
+		description: "Number of converted items by turnaround bucket.",
+		unit:        "histogram",
+		appIn:       "count(converted) by completedAt - createdAt in <30min, <1h, <2h, <3h, <4h, <5h, 5h+",
+		caFinal:     "count(converted) by completedAt - createdAt in <30min, <1h, <2h, <3h, <4h, <5h, 5h+",
+	},
+	{
+		field:       "timeIntervalsByPending",
+		description: "Number of pending items by age bucket.",
+		unit:        "histogram",
+		appIn:       "count(pending) by now - createdAt in <30min, <1h, <2h, <3h, <4h, <5h, 5h+",
+		caFinal:     "count(pending) by now - createdAt in <30min, <1h, <2h, <3h, <4h, <5h, 5h+",
+	},
+	{
+		field:       "bestTimeUsed",
+		description: "Executor with the shortest best time.",
+		unit:        "name",
+		appIn:       "argmin(executor bestTime > 0)",
+		caFinal:     "argmin(executor bestTime > 0)",
+	},
+	{
+		field:       "leaderboardTotal",
+		description: "Number of executors eligible for the leaderboard.",
+		unit:        "count",
+		appIn:       "count(executor where total >= leaderboardMinVolume)",
+		caFinal:     "count(executor where total >= leaderboardMinVolume)",
+	},
+	{
+		field:       "leaderboards.rank",
+		description: "Rank of the executor by leaderboardSort. Ties share a rank.",
+		unit:        "count",
+		appIn:       "competition or dense rank by leaderboardSort",
+		caFinal:     "competition or dense rank by leaderboardSort",
+	},
+	{
+		field:       "leaderboards.total",
+		description: "Number of items of the executor.",
+		unit:        "count",
+		appIn:       "count(items of executor)",
+		caFinal:     "count(items of executor)",
+	},
+	{
+		field:       "leaderboards.converted",
+		description: "conversion.converted of the executor.",
+		unit:        "count",
+		appIn:       "count(outcome = converted and completedAt set of executor)",
+		caFinal:     "count(outcome = converted and completedAt set of executor)",
+	},
+	{
+		field:       "leaderboards.conversionRate",
+		description: "conversion.rate of the executor.",
+		unit:        "percent",
+		appIn:       "(converted + notPassed) / total * 100",
+		caFinal:     "converted / total * 100",
+	},
+	{
+		field:       "leaderboards.averageTime",
+		description: "conversion.averageTime of the executor.",
+		unit:        "duration",
+		appIn:       "sum(completedAt - createdAt of converted) / (converted + notPassed)",
+		caFinal:     "sum(completedAt - createdAt of converted) / converted",
+	},
+	{
+		field:       "leaderboards.bestTime",
+		description: "conversion.bestTime of the executor.",
+		unit:        "duration",
+		appIn:       "min(completedAt - createdAt) of converted where >= 1m",
+		caFinal:     "min(completedAt - createdAt) of converted where >= 1m",
+	},
+	{
+		field:       "leaderboards.p90Time",
+		description: "90th percentile turnaround of the converted items of the executor.",
+		unit:        "duration",
+		appIn:       "p90(completedAt - createdAt of converted), nearest rank",
+		caFinal:     "p90(completedAt - createdAt of converted), nearest rank",
+	},
+	{
+		field:       "leaderboards.conversionInterval",
+		description: "95% Wilson score interval of leaderboards.conversionRate.",
+		unit:        "percent",
+		appIn:       "wilson(converted + notPassed, total, z = 1.96) * 100",
+		caFinal:     "wilson(converted, total, z = 1.96) * 100",
+	},
+	{
+		field:       "leaderboards.adjustedRate",
+		description: "leaderboards.conversionRate smoothed toward the peer average.",
+		unit:        "percent",
+		appIn:       "(converted + notPassed + peerRate * 10) / (total + 10) * 100",
+		caFinal:     "(converted + peerRate * 10) / (total + 10) * 100",
+	},
+	{
+		field:       "leaderboards.score",
+		description: "Composite performance score of the executor from the configured weights.",
+		unit:        "score",
+		appIn:       "sum(weight * minmax(input) across peers) / sum(weight) * 100",
+		caFinal:     "sum(weight * minmax(input) across peers) / sum(weight) * 100",
+	},
+	{
+		field:       "leaderboards.performances",
+		description: "Number of items converted by the executor by turnaround bucket.",
+		unit:        "histogram",
+		appIn:       "timeIntervalsByConverted of executor",
+		caFinal:     "timeIntervalsByConverted of executor",
+	},
+	{
+		field:       "productMetrics.total",
+		description: "Number of items of the product label of the grouping scheme.",
+		unit:        "count",
+		appIn:       "count(items of product label)",
+		caFinal:     "count(items of product label)",
+	},
+	{
+		field:       "productMetrics.converted",
+		description: "conversion.converted of the product label of the grouping scheme.",
+		unit:        "count",
+		appIn:       "count(outcome = converted and completedAt set of product label)",
+		caFinal:     "count(outcome = converted and completedAt set of product label)",
+	},
+	{
+		field:       "productMetrics.notPassed",
+		description: "conversion.notPassed of the product label of the grouping scheme.",
+		unit:        "count",
+		appIn:       "count(outcome = rejected of product label)",
+		caFinal:     "count(outcome = rejected of product label)",
+	},
+	{
+		field:       "productMetrics.conversionRate",
+		description: "conversion.rate of the product label of the grouping scheme.",
+		unit:        "percent",
+		appIn:       "(converted + notPassed) / total * 100",
+		caFinal:     "converted / total * 100",
+	},
+	{
+		field:       "productMetrics.averageTime",
+		description: "conversion.averageTime of the product label of the grouping scheme.",
+		unit:        "duration",
+		appIn:       "sum(completedAt - createdAt of converted) / (converted + notPassed)",
+		caFinal:     "sum(completedAt - createdAt of converted) / converted",
+	},
+	{
+		field:       "sla.within",
+		description: "Number of items within their SLA target and, if pending, their SLA warning.",
+		unit:        "count",
+		appIn:       "count(elapsed <= sla target and not atRisk)",
+		caFinal:     "count(elapsed <= sla target and not atRisk)",
+	},
+	{
+		field:       "sla.compliance",
+		description: "Share of items within their SLA target. Pending items past their warning are at risk.",
+		unit:        "percent",
+		appIn:       "within / (breached + atRisk + within) * 100",
+		caFinal:     "within / (breached + atRisk + within) * 100",
+	},
+	{
+		field:       "sla.breached",
+		description: "Number of items that took or are taking longer than their SLA target.",
+		unit:        "count",
+		appIn:       "count(elapsed > sla target)",
+		caFinal:     "count(elapsed > sla target)",
+	},
+	{
+		field:       "sla.atRisk",
+		description: "Number of pending items past their SLA warning but not their target.",
+		unit:        "count",
+		appIn:       "count(pending and sla warning < age <= sla target)",
+		caFinal:     "count(pending and sla warning < age <= sla target)",
+	},
+	{
+		field:       "sla.breaches",
+		description: "Items that took or are taking longer than their SLA target, the longest first.",
+		unit:        "list",
+		appIn:       "items where elapsed > sla target, overdue = elapsed - sla target",
+		caFinal:     "items where elapsed > sla target, overdue = elapsed - sla target",
+	},
+	{
+		field:       "sla.executors",
+		description: "Number of breached and at-risk items of each executor who owns any, the most breaches first.",
+		unit:        "list",
+		appIn:       "count(breached), count(atRisk) by executor",
+		caFinal:     "count(breached), count(atRisk) by executor",
+	},
+	{
+		field:       "comparison.conversion",
+		description: "Change of each conversion metric against the comparison period.",
+		unit:        "delta",
+		appIn:       "current - previous, (current - previous) / previous * 100",
+		caFinal:     "current - previous, (current - previous) / previous * 100",
+	},
+	{
+		field:       "comparison.productMetrics",
+		description: "Change of the metrics of each product label against the comparison period.",
+		unit:        "delta",
+		appIn:       "current - previous, (current - previous) / previous * 100",
+		caFinal:     "current - previous, (current - previous) / previous * 100",
+	},
+	{
+		field:       "comparison.leaderboards",
+		description: "Change of the metrics and rank of each leaderboard entry against the comparison period.",
+		unit:        "delta",
+		appIn:       "current - previous, (current - previous) / previous * 100",
+		caFinal:     "current - previous, (current - previous) / previous * 100",
+	},
+}
+
+// overviewMetrics is the metrics of the overview that span every stage.
+var overviewMetrics = []*Metric{
+	{
+		ID:          "overview.unmappedStatuses",
+		Path:        "unmappedStatuses",
+		Description: "Raw statuses of every stage no rule of the status taxonomy matches, the most frequent first.",
+		Formula:     "count(items where status matches no rule) by stage and status",
+		Unit:        "list",
+	},
+	{
+		ID:          "overview.customMetrics",
+		Path:        "customMetrics",
+		Description: "Value of each custom metric, in configuration order. See the custom metrics of the catalog.",
+		Formula:     "aggregate of the items of the stage of each custom metric",
+		Unit:        "list",
+	},
+}
+
+// rejectionMetrics is the metrics of CA Final and the configured stages.
+var rejectionMetrics = []*Metric{
+	{
+		Path:        "rejections.total",
+		Description: "Number of rejected, cancelled or returned items of the reason.",
+		Formula:     "count(outcome and reason)",
+		Unit:        "count",
+	},
+	{
+		Path:        "rejections.share",
+		Description: "Share of the items of the outcome with the reason.",
+		Formula:     "count(outcome and reason) / count(outcome) * 100",
+		Unit:        "percent",
+	},
+}
+
+// appInMetrics is the metrics of App-In only.
+var appInMetrics = []*Metric{
+	{
+		Path:        "submitterTotal",
+		Description: "Number of submitters eligible for the list.",
+		Formula:     "count(createdBy where total >= submitterMinVolume)",
+		Unit:        "count",
+	},
+	{
+		Path:        "submitters.total",
+		Description: "Number of App-In of the submitter.",
+		Formula:     "count(items of createdBy)",
+		Unit:        "count",
+	},
+	{
+		Path:        "submitters.converted",
+		Description: "Number of App-In of the submitter converted.",
+		Formula:     "count(outcome = converted and completedAt set of createdBy)",
+		Unit:        "count",
+	},
+	{
+		Path:        "submitters.notPassed",
+		Description: "Number of App-In of the submitter rejected.",
+		Formula:     "count(outcome = rejected of createdBy)",
+		Unit:        "count",
+	},
+	{
+		Path:        "submitters.conversionRate",
+		Description: "Share of the App-In of the submitter converted.",
+		Formula:     "converted / total * 100",
+		Unit:        "percent",
+	},
+	{
+		Path:        "submitters.notPassRate",
+		Description: "Share of the App-In of the submitter rejected.",
+		Formula:     "notPassed / total * 100",
+		Unit:        "percent",
+	},
+	{
+		Path:        "submitters.averageTurnaround",
+		Description: "Average turnaround of the completed App-In of the submitter.",
+		Formula:     "avg(completedAt - createdAt) of not pending with completedAt",
+		Unit:        "duration",
+	},
+	{
+		Path:        "submitters.customerTypes",
+		Description: "Customer type mix of the App-In of the submitter, the most frequent first.",
+		Formula:     "count(items of createdBy) by customerType, share = count / total * 100",
+		Unit:        "list",
+	},
+	{
+		Path:        "value.overall.count",
+		Description: "Number of App-In with a parsed finance amount.",
+		Formula:     "count(financeAmount)",
+		Unit:        "count",
+	},
+	{
+		Path:        "value.overall.total",
+		Description: "Total parsed finance amount.",
+		Formula:     "sum(financeAmount)",
+		Unit:        "decimal",
+	},
+	{
+		Path:        "value.overall.average",
+		Description: "Average parsed finance amount.",
+		Formula:     "sum(financeAmount) / count(financeAmount)",
+		Unit:        "decimal",
+	},
+	{
+		Path:        "value.overall.median",
+		Description: "Median parsed finance amount.",
+		Formula:     "median(financeAmount)",
+		Unit:        "decimal",
+	},
+	{
+		Path:        "value.overall.converted",
+		Description: "Total parsed finance amount of converted App-In.",
+		Formula:     "sum(financeAmount of converted)",
+		Unit:        "decimal",
+	},
+	{
+		Path:        "value.overall.convertedCount",
+		Description: "Number of converted App-In with a parsed finance amount.",
+		Formula:     "count(financeAmount of converted)",
+		Unit:        "count",
+	},
+	{
+		Path:        "value.overall.averageTerm",
+		Description: "Average parsed term in months.",
+		Formula:     "sum(term) / count(term)",
+		Unit:        "number",
+	},
+	{
+		Path:        "value.byProduct",
+		Description: "value.overall of each product, the largest total first.",
+		Formula:     "value.overall by product",
+		Unit:        "list",
+	},
+	{
+		Path:        "value.byExecutor",
+		Description: "value.overall of each executor, the largest total first.",
+		Formula:     "value.overall by executor",
+		Unit:        "list",
+	},
+	{
+		Path:        "value.byPeriod",
+		Description: "value.overall of each period of the interval, the earliest first.",
+		Formula:     "value.overall by createdAt truncated to interval",
+		Unit:        "list",
+	},
+	{
+		Path:        "outliers.appIn",
+		Description: "App-In with a suspicious turnaround.",
+		Formula:     "converted where turnaround is an outlier by outlierMethod among product or executor, or completed in the same minute as other items of the executor",
+		Unit:        "list",
+	},
+}
+
+// caFinalMetrics is the metrics of CA Final only.
+var caFinalMetrics = []*Metric{
+	{
+		Path:        "outliers.caFinal",
+		Description: "CA Final with a suspicious turnaround.",
+		Formula:     "converted where turnaround is an outlier by outlierMethod among product or executor, or completed in the same minute as other items of the executor",
+		Unit:        "list",
+	},
+}
+
+// newMetricCatalog returns the definition of every metric of the overview with the stages and custom metrics.
+func newMetricCatalog(stages StageConfigs, custom CustomMetrics) []*Metric {
+	ms := make([]*Metric, 0)
+	ms = append(ms, newStageMetrics(appInStage, "")...)
+	ms = append(ms, withStage(appInStage.Name, "", appInMetrics)...)
+
+	ms = append(ms, newStageMetrics(caFinalStage, "caFinalOverview.")...)
+	ms = append(ms, withStage(caFinalStage.Name, "caFinalOverview.", rejectionMetrics)...)
+	ms = append(ms, withStage(caFinalStage.Name, "", caFinalMetrics)...)
+
+	for i, st := range stages {
+		prefix := fmt.Sprintf("stages[%d].", i)
+		ms = append(ms, newStageMetrics(st, prefix)...)
+		ms = append(ms, withStage(st.Name, prefix, rejectionMetrics)...)
+	}
+
+	for _, m := range overviewMetrics {
+		c := *m
+		ms = append(ms, &c)
+	}
+
+	for i, m := range custom {
+		description := m.Name
+		if description == "" {
+			description = "Custom metric " + m.ID + "."
+		}
+
+		ms = append(ms, &Metric{
+			ID:          "custom." + m.ID,
+			Stage:       m.Stage,
+			Path:        fmt.Sprintf("customMetrics[%d].value", i),
+			Description: description,
+			Formula:     customMetricFormula(m),
+			Unit:        customMetricUnit(m),
+		}, &Metric{
+			ID:          "custom." + m.ID + ".count",
+			Stage:       m.Stage,
+			Path:        fmt.Sprintf("customMetrics[%d].count", i),
+			Description: "Number of items aggregated by " + m.ID + ".",
+			Formula:     customMetricCountFormula(m),
+			Unit:        "count",
+		})
+	}
+
+	return ms
+}
+
+// newStageMetrics returns the shared metrics of a stage overview at the path prefix in the overview.
+func newStageMetrics(st *StageConfig, prefix string) []*Metric {
+	ms := make([]*Metric, 0, len(sharedMetrics))
+	for _, m := range sharedMetrics {
+		description := m.description
+		if st.Name == StageCAFinal && m.caFinalDescription != "" {
+			description = m.caFinalDescription
+		}
+
+		ms = append(ms, &Metric{
+			ID:          string(st.Name) + "." + m.field,
+			Stage:       st.Name,
+			Path:        prefix + m.field,
+			Description: description,
+			Formula:     m.formula(st),
+			Unit:        m.unit,
+		})
+	}
+
+	return ms
+}
+
+// withStage returns a copy of the metrics of a stage at the path prefix in the overview.
+func withStage(stage Stage, prefix string, metrics []*Metric) []*Metric {
+	ms := make([]*Metric, 0, len(metrics))
+	for _, m := range metrics {
+		c := *m
+		c.ID = string(stage) + "." + m.Path
+		c.Stage = stage
+		c.Path = prefix + m.Path
+		ms = append(ms, &c)
+	}
+
+	return ms
+}
+
+// customMetricFormula returns the formula of a custom metric from its expressions.
+func customMetricFormula(m *CustomMetric) string {
+	items := func(expr string) string {
+		if expr == "" {
+			return "items"
+		}
+		return "items where " + expr
+	}
+
+	switch m.Aggregate {
+	case AggregateCount:
+		return fmt.Sprintf("count(%s)", items(m.Filter))
+	case AggregateRatio:
+		matched := m.Filter
+		if m.Over != "" && matched != "" {
+			matched = fmt.Sprintf("(%s) && (%s)", m.Over, m.Filter)
+		} else if m.Over != "" {
+			matched = m.Over
+		}
+		return fmt.Sprintf("count(%s) / count(%s) * 100", items(matched), items(m.Over))
+	case AggregatePercentile:
+		return fmt.Sprintf("p%s(%s of %s), nearest rank", strconv.FormatFloat(m.Percentile, 'f', -1, 64), m.Value, items(m.Filter))
+	default:
+		return fmt.Sprintf("%s(%s of %s)", m.Aggregate, m.Value, items(m.Filter))
+	}
+}
+
+// customMetricCountFormula returns the formula of the number of items aggregated by a custom metric.
+// Items with a null value are not aggregated.
+func customMetricCountFormula(m *CustomMetric) string {
+	items := "items"
+	switch {
+	case m.Aggregate == AggregateRatio && m.Over != "":
+		items += " where " + m.Over
+	case m.Aggregate != AggregateRatio && m.Filter != "":
+		items += " where " + m.Filter
+	}
+
+	if m.Aggregate.needsValue() {
+		return fmt.Sprintf("count(%s of %s)", m.Value, items)
+	}
+	return fmt.Sprintf("count(%s)", items)
+}
+
+// customMetricUnit returns the unit of a custom metric. The value of sum, avg, min, max and percentile is a plain number.
+func customMetricUnit(m *CustomMetric) string {
+	switch m.Aggregate {
+	case AggregateCount:
+		return "count"
+	case AggregateRatio:
+		return "percent"
+	default:
+		return "number"
+	}
+}
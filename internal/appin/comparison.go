@@ -0,0 +1,202 @@
+package appin
+
+import "time"
+
+// Comparison is the change of an overview against a comparison period.
+type Comparison struct {
+	// CreatedAfter is the start of the comparison period.
+	CreatedAfter time.Time `json:"createdAfter"`
+
+	// CreatedBefore is the end of the comparison period.
+	CreatedBefore time.Time `json:"createdBefore"`
+
+	// Conversion is the change of the conversion metrics.
+	Conversion *ConversionDelta `json:"conversion"`
+
+	// ProductMetrics is the change of the metrics of each product.
+	ProductMetrics []*ProductMetricsDelta `json:"productMetrics"`
+
+	// Leaderboards is the change of each leaderboard entry.
+	Leaderboards []*LeaderboardDelta `json:"leaderboards"`
+}
+
+// Delta is the change of a metric between the current and the comparison period.
+type Delta struct {
+	// Current is the value in the current period.
+	Current float64 `json:"current"`
+
+	// Previous is the value in the comparison period.
+	Previous float64 `json:"previous"`
+
+	// Change is the absolute change. Current - Previous.
+	Change float64 `json:"change"`
+
+	// PercentChange is the change relative to the previous value.
+	// Zero when the previous value is zero.
+	PercentChange float64 `json:"percentChange"`
+}
+
+// ConversionDelta is the change of the conversion metrics.
+type ConversionDelta struct {
+	Total          *Delta `json:"total"`
+	Converted      *Delta `json:"converted"`
+	NotPassed      *Delta `json:"notPassed"`
+	Rate           *Delta `json:"rate"`
+	Fastest        *Delta `json:"fastest"`
+	FastestPercent *Delta `json:"fastestPercent"`
+	NeedAttention  *Delta `json:"needAttention"`
+	BestTime       *Delta `json:"bestTime"`
+	AverageTime    *Delta `json:"averageTime"`
+}
+
+// ProductMetricsDelta is the change of the metrics of a product.
+type ProductMetricsDelta struct {
+	// Name is the name of the product.
+	Name string `json:"name"`
+
+	Total          *Delta `json:"total"`
+	Converted      *Delta `json:"converted"`
+	NotPassed      *Delta `json:"notPassed"`
+	ConversionRate *Delta `json:"conversionRate"`
+	AverageTime    *Delta `json:"averageTime"`
+}
+
+// LeaderboardDelta is the change of a leaderboard entry.
+type LeaderboardDelta struct {
+	// DisplayName is the display name of the performer.
+	DisplayName string `json:"displayName"`
+
+	// Rank is the rank in the current period.
+	Rank int64 `json:"rank"`
+
+	// PreviousRank is the rank in the comparison period. Zero if the performer was not ranked.
+	PreviousRank int64 `json:"previousRank"`
+
+	// RankChange is the number of places moved. Positive means moved up.
+	// Zero if the performer was not ranked in the comparison period.
+	RankChange int64 `json:"rankChange"`
+
+	Total          *Delta `json:"total"`
+	Converted      *Delta `json:"converted"`
+	ConversionRate *Delta `json:"conversionRate"`
+	AverageTime    *Delta `json:"averageTime"`
+	BestTime       *Delta `json:"bestTime"`
+}
+
+// SetComparison sets the change of the overview against the App-In and CA Final of the comparison period.
+func (o *Overview) SetComparison(q *Query, previous []*AppIn, previousCA []*CAFinal, opts *OverviewOptions) {
+	o.Comparison = newComparison(appInStage, q, o.Conversion, o.ProductMetrics, o.Leaderboards, records(previous), opts)
+
+	if o.CAFinalOverview != nil {
+		o.CAFinalOverview.SetComparison(q, records(previousCA), opts)
+	}
+}
+
+// SetComparison sets the change of the stage overview against the records of the comparison period.
+func (so *StageOverview) SetComparison(q *Query, previous []*Record, opts *OverviewOptions) {
+	so.Comparison = newComparison(so.config, q, so.Conversion, so.ProductMetrics, so.Leaderboards, previous, opts)
+}
+
+func newComparison(st *StageConfig, q *Query, c *Conversion, products []*ProductMetrics, leaderboards []*Leaderboard, previous []*Record, opts *OverviewOptions) *Comparison {
+	sla, lo := opts.SLA, opts.Leaderboard
+	performers := calculateConversionMetricsByExecutor(st, groupByExecutor(previous), sla)
+	return &Comparison{
+		CreatedAfter:   q.CreatedAfter,
+		CreatedBefore:  q.CreatedBefore,
+		Conversion:     compareConversion(c, newConversion(st, previous, sla)),
+		ProductMetrics: compareProductMetrics(products, calculateConversionMetricsByProduct(st, previous, sla, opts.Grouping)),
+		Leaderboards:   compareLeaderboards(leaderboards, rankPerformers(performers, lo)),
+	}
+}
+
+func newDelta(current, previous float64) *Delta {
+	d := &Delta{
+		Current:  current,
+		Previous: previous,
+		Change:   current - previous,
+	}
+
+	if previous != 0 {
+		d.PercentChange = d.Change / previous * 100
+	}
+
+	return d
+}
+
+func compareConversion(current, previous *Conversion) *ConversionDelta {
+	return &ConversionDelta{
+		Total:          newDelta(float64(current.Total), float64(previous.Total)),
+		Converted:      newDelta(float64(current.Converted), float64(previous.Converted)),
+		NotPassed:      newDelta(float64(current.NotPassed), float64(previous.NotPassed)),
+		Rate:           newDelta(float64(current.Rate), float64(previous.Rate)),
+		Fastest:        newDelta(float64(current.Fastest), float64(previous.Fastest)),
+		FastestPercent: newDelta(float64(current.FastestPercent), float64(previous.FastestPercent)),
+		NeedAttention:  newDelta(float64(current.NeedAttention), float64(previous.NeedAttention)),
+		BestTime:       newDelta(float64(current.BestTime), float64(previous.BestTime)),
+		AverageTime:    newDelta(float64(current.AverageTime), float64(previous.AverageTime)),
+	}
+}
+
+// compareProductMetrics compares the current products against the previous ones by name.
+// A product missing in the previous period is compared against zero.
+func compareProductMetrics(current, previous []*ProductMetrics) []*ProductMetricsDelta {
+	byName := make(map[string]*ProductMetrics, len(previous))
+	for _, p := range previous {
+		byName[p.Name] = p
+	}
+
+	ds := make([]*ProductMetricsDelta, 0, len(current))
+	for _, c := range current {
+		p, ok := byName[c.Name]
+		if !ok {
+			p = &ProductMetrics{Name: c.Name}
+		}
+
+		ds = append(ds, &ProductMetricsDelta{
+			Name:           c.Name,
+			Total:          newDelta(float64(c.Total), float64(p.Total)),
+			Converted:      newDelta(float64(c.Converted), float64(p.Converted)),
+			NotPassed:      newDelta(float64(c.NotPassed), float64(p.NotPassed)),
+			ConversionRate: newDelta(float64(c.ConversionRate), float64(p.ConversionRate)),
+			AverageTime:    newDelta(float64(c.AverageTime), float64(p.AverageTime)),
+		})
+	}
+
+	return ds
+}
+
+// compareLeaderboards compares each leaderboard entry against the full ranking of the previous period.
+func compareLeaderboards(current []*Leaderboard, previous []*rankedPerformer) []*LeaderboardDelta {
+	ranks := make(map[string]int64, len(previous))
+	metrics := make(map[string]*Conversion, len(previous))
+	for _, p := range previous {
+		ranks[p.DisplayName] = p.Rank
+		metrics[p.DisplayName] = p.Conversion
+	}
+
+	ds := make([]*LeaderboardDelta, 0, len(current))
+	for _, l := range current {
+		c, ok := metrics[l.DisplayName]
+		if !ok {
+			c = new(Conversion)
+		}
+
+		d := &LeaderboardDelta{
+			DisplayName:    l.DisplayName,
+			Rank:           l.Rank,
+			PreviousRank:   ranks[l.DisplayName],
+			Total:          newDelta(float64(l.Total), float64(c.Total)),
+			Converted:      newDelta(float64(l.Converted), float64(c.Converted)),
+			ConversionRate: newDelta(float64(l.ConversionRate), float64(c.Rate)),
+			AverageTime:    newDelta(float64(l.AverageTime), float64(c.AverageTime)),
+			BestTime:       newDelta(float64(l.BestTime), float64(c.BestTime)),
+		}
+		if d.PreviousRank > 0 {
+			d.RankChange = d.PreviousRank - d.Rank
+		}
+
+		ds = append(ds, d)
+	}
+
+	return ds
+}
@@ -0,0 +1,58 @@
+package appin
+
+import "math"
+
+const (
+	// wilsonZ is the z-score of the 95% Wilson score interval.
+	wilsonZ = 1.96
+
+	// bayesPriorStrength is the number of pseudo items of the peer average added to each performer.
+	bayesPriorStrength = 10
+)
+
+// RateInterval is a confidence interval of a rate in percent.
+type RateInterval struct {
+	Lower float32 `json:"lower"`
+	Upper float32 `json:"upper"`
+}
+
+// wilsonInterval returns the 95% Wilson score interval of successes out of trials.
+func wilsonInterval(successes, trials int64) *RateInterval {
+	if trials == 0 {
+		return &RateInterval{Lower: 0, Upper: 100}
+	}
+
+	n := float64(trials)
+	p := float64(successes) / n
+	z2 := wilsonZ * wilsonZ
+
+	center := (p + z2/(2*n)) / (1 + z2/n)
+	margin := wilsonZ * math.Sqrt(p*(1-p)/n+z2/(4*n*n)) / (1 + z2/n)
+
+	return &RateInterval{
+		Lower: float32(math.Max(center-margin, 0) * 100),
+		Upper: float32(math.Min(center+margin, 1) * 100),
+	}
+}
+
+// setConfidence sets the Wilson interval and the Bayesian-smoothed rate of each performer.
+// Both are of the same rate as Conversion.Rate, so rejected items count as processed in the stages that say so.
+// The prior is the conversion rate of the whole peer group.
+func setConfidence(performers []*performerMetric) {
+	var processed, total int64
+	for _, p := range performers {
+		processed += p.Conversion.processed
+		total += p.Conversion.Total
+	}
+
+	prior := 0.0
+	if total > 0 {
+		prior = float64(processed) / float64(total)
+	}
+
+	for _, p := range performers {
+		p.Interval = wilsonInterval(p.Conversion.processed, p.Conversion.Total)
+		p.AdjustedRate = float32((float64(p.Conversion.processed) + prior*bayesPriorStrength) /
+			(float64(p.Conversion.Total) + bayesPriorStrength) * 100)
+	}
+}
@@ -1,9 +1,8 @@
 package appin
 
 import (
-	"fmt"
+	"net/url"
 	"sort"
-	"strings"
 	"time"
 )
 
@@ -28,80 +27,76 @@ type Overview struct {
 	BestTimeUsed *BestTimeExecutor `json:"bestTimeUsed"`
 
 	// Leaderboard is the leaderboard of App-In.
-	// Top 5 performers
+	// Top 5 performers unless LeaderboardOptions says otherwise.
 	Leaderboards []*Leaderboard `json:"leaderboards"`
 
+	// LeaderboardTotal is the number of executors eligible for the leaderboard.
+	LeaderboardTotal int64 `json:"leaderboardTotal"`
+
+	// Submitters is the quality of the App-In submitted by each person, the most App-In first.
+	// Top 5 submitters unless SubmitterOptions says otherwise.
+	Submitters []*SubmitterMetrics `json:"submitters"`
+
+	// SubmitterTotal is the number of submitters eligible for the list.
+	SubmitterTotal int64 `json:"submitterTotal"`
+
 	// ProductMetrics is the product metrics of App-In.
 	ProductMetrics []*ProductMetrics `json:"productMetrics"`
 
 	// CAFinalOverview is the CA operation performed by App-In.
-	CAFinalOverview *CAFinalOverview `json:"caFinalOverview"`
-}
+	CAFinalOverview *StageOverview `json:"caFinalOverview"`
 
-func newOverview(appIns []*AppIn) *Overview {
-	groups := groupAppInByExecutor(appIns)
-	performances := calculatePerformanceConversionMetricsByExecutor(groups)
-	o := new(Overview)
+	// Stages is the overview of each configured stage after CA Final, in pipeline order.
+	Stages []*StageOverview `json:"stages"`
 
-	o.ActiveExecutor = int64(len(groups))
-	o.TopPerformer = getTopPerformer(performances)
-	o.Conversion = newConversion(appIns)
-	o.Leaderboards = createLeaderboards(performances)
+	// SLA is the SLA compliance of App-In.
+	SLA *SLAReport `json:"sla"`
 
-	o.TimeIntervalsByConverted = createTimeIntervalsByConverted(appIns)
-	o.BestTimeUsed = findBestTimeUsedByExecutor(performances)
+	// Value is the loan value financed by App-In.
+	Value *ValueOverview `json:"value"`
 
-	o.TimeIntervalsByPending = createTimeIntervalsByPending(appIns)
-	o.ProductMetrics = calculatePerformanceConversionMetricsByProduct(appIns)
+	// CustomMetrics is the value of the user-defined metrics.
+	CustomMetrics []*CustomMetricValue `json:"customMetrics"`
 
-	return o
-}
+	// MetricCatalog is the path of the definition of the metrics of the overview. See ListMetricCatalog.
+	MetricCatalog string `json:"metricCatalog"`
 
-// SetCAFinal sets the CA operation performed by App-In.
-func (o *Overview) SetCAFinal(ca []*CAFinal) {
-	o.CAFinalOverview = newCAFinalOverview(ca)
-}
-
-func newCAFinalOverview(appins []*CAFinal) *CAFinalOverview {
-	groups := groupCAFinalByExecutor(appins)
-	performances := calculateCAFinalConversionMetricsByExecutor(groups)
+	// Outliers is the App-In and CA Final with a suspicious turnaround.
+	Outliers *OutlierReport `json:"outliers"`
 
-	c := new(CAFinalOverview)
-	c.ActiveExecutor = int64(len(groups))
-	c.TopPerformer = getTopPerformer(performances)
-	c.Leaderboards = createLeaderboards(performances)
-	c.BestTimeUsed = findBestTimeUsedByExecutor(performances)
+	// UnmappedStatuses is the statuses of every stage no rule of the status taxonomy matches.
+	UnmappedStatuses []*UnmappedStatus `json:"unmappedStatuses"`
 
-	c.Conversion = newCAFinalConversion(appins)
-	c.TimeIntervalsByConverted = createCAFinalTimeIntervalsByConverted(appins)
-	c.TimeIntervalsByPending = createCAFinalTimeIntervalsByPending(appins)
-
-	return c
+	// Comparison is the change against the comparison period.
+	// Nil when no comparison is requested.
+	Comparison *Comparison `json:"comparison"`
 }
 
-// CAFinalOverview is the CA operation performed by App-In.
-type CAFinalOverview struct {
-	// ActiveExecutor is the number of active executor.
-	ActiveExecutor int64 `json:"activeExecutor"`
-
-	// TopPerformer is the top performer who performed the most App-In.
-	TopPerformer *TopPerformer `json:"topPerformer"`
-
-	// Conversion is the conversion rate.
-	Conversion *Conversion `json:"conversion"`
-
-	// TimeIntervalsByConverted is the time intervals for converted App-In.
-	TimeIntervalsByConverted []*TimeInterval `json:"timeIntervalsByConverted"`
-
-	// TimeIntervalsByPending is the time intervals for pending App-In.
-	TimeIntervalsByPending []*TimeInterval `json:"timeIntervalsByPending"`
+func newOverview(appIns []*AppIn, opts *OverviewOptions) *Overview {
+	so := newStageOverview(appInStage, records(appIns), opts)
+	o := &Overview{
+		ActiveExecutor:           so.ActiveExecutor,
+		TopPerformer:             so.TopPerformer,
+		Conversion:               so.Conversion,
+		TimeIntervalsByConverted: so.TimeIntervalsByConverted,
+		TimeIntervalsByPending:   so.TimeIntervalsByPending,
+		BestTimeUsed:             so.BestTimeUsed,
+		Leaderboards:             so.Leaderboards,
+		LeaderboardTotal:         so.LeaderboardTotal,
+		ProductMetrics:           so.ProductMetrics,
+		SLA:                      so.SLA,
+	}
+
+	o.Submitters, o.SubmitterTotal = createSubmitterMetrics(appIns, opts.Submitter)
+	o.Value = newValueOverview(appIns, opts.Interval, opts.Location)
+	o.MetricCatalog = MetricCatalogPath
 
-	// BestTimeUsed is the executor with the best time used for App-In.
-	BestTimeUsed *BestTimeExecutor `json:"bestTimeUsed"`
+	return o
+}
 
-	// Leaderboard is the leaderboard of App-In.
-	// Top 5 performers
-	Leaderboards []*Leaderboard `json:"leaderboards"`
+// SetCAFinal sets the CA operation performed by App-In.
+func (o *Overview) SetCAFinal(ca []*CAFinal, opts *OverviewOptions) {
+	o.CAFinalOverview = newStageOverview(caFinalStage, records(ca), opts)
 }
 
 // BestTimeExecutor is the executor with the best time used for App-In.
@@ -124,6 +119,12 @@ type Conversion struct {
 	// NotPassed is the number of App-In not passed.
 	NotPassed int64 `json:"notPassed"`
 
+	// Cancelled is the number of App-In cancelled.
+	Cancelled int64 `json:"cancelled"`
+
+	// Returned is the number of App-In returned.
+	Returned int64 `json:"returned"`
+
 	// Rate is the conversion rate.
 	Rate float32 `json:"rate"`
 
@@ -133,7 +134,7 @@ type Conversion struct {
 	// FastestPercent is the percentage of App-In performed in the shortest time.
 	FastestPercent float32 `json:"fastestPercent"`
 
-	// Slowest is the number of App-In performed in the longest time. Over 5h.
+	// NeedAttention is the number of pending App-In past their SLA target.
 	NeedAttention int64 `json:"needAttention"`
 
 	// BestTime is the best time used for App-In.
@@ -141,6 +142,9 @@ type Conversion struct {
 
 	// AverageTime is the average time for App-in performed.
 	AverageTime time.Duration `json:"averageTime"`
+
+	// processed is the numerator of Rate: converted, plus rejected if the stage counts them as processed.
+	processed int64
 }
 
 // TimeInterval is the time interval for App-In.
@@ -150,6 +154,13 @@ type TimeInterval struct {
 
 	// Total is the total number of App-In.
 	Total int64 `json:"total"`
+
+	// Key is the stable drill-down key of the time interval. ex: "5h+"
+	Key string `json:"key"`
+
+	// DrillDown is the query parameters that list the items of the time interval.
+	// The interval of a leaderboard entry also has the executor. ex: "pendingBucket=5h%2B", "convertedBucket=%3C1h&executor=Somchai"
+	DrillDown string `json:"drillDown"`
 }
 
 type Leaderboard struct {
@@ -174,6 +185,19 @@ type Leaderboard struct {
 	// BestTime is the best time used for App-In by the performer.
 	BestTime time.Duration `json:"bestTime"`
 
+	// P90Time is the 90th percentile of the time used for App-In converted by the performer.
+	P90Time time.Duration `json:"p90Time"`
+
+	// ConversionInterval is the 95% Wilson score interval of ConversionRate.
+	ConversionInterval *RateInterval `json:"conversionInterval"`
+
+	// AdjustedRate is ConversionRate smoothed toward the average of the peers,
+	// so performers with few App-In are not ranked on luck.
+	AdjustedRate float32 `json:"adjustedRate"`
+
+	// Score is the composite performance score of the performer against the peers.
+	Score *Score `json:"score"`
+
 	// Performances is the performance of the performer for each time interval.
 	Performances []*TimeInterval `json:"performances"`
 }
@@ -211,19 +235,18 @@ type TopPerformer struct {
 	ConversionRate float32 `json:"conversionRate"`
 }
 
-// newConversion calculates and returns conversion metrics from a slice of AppIn.
-func newConversion(appIns []*AppIn) *Conversion {
-	total := int64(len(appIns))
+// newConversion calculates and returns conversion metrics from the records of a stage.
+// Rejected records count as processed only if the stage says so.
+func newConversion(st *StageConfig, rs []*Record, sla *SLAPolicy) *Conversion {
+	total := int64(len(rs))
 
 	var sum, bestTime time.Duration
-	var fastestCount, needAttention, converted, notPassed int64
-	const threshold = 5 * time.Hour
+	var fastestCount, needAttention, converted, notPassed, cancelled, returned int64
 
-	for _, appIn := range appIns {
-		status := strings.ToLower(appIn.Status)
-		if len(status) > 0 && !strings.Contains(status, "not pass") && appIn.CompletedAt != nil {
+	for _, r := range rs {
+		if r.converted() {
 			converted++
-			duration := appIn.CompletedAt.Sub(appIn.CreatedAt)
+			duration := r.CompletedAt.Sub(r.CreatedAt)
 			sum += duration
 
 			if duration <= time.Minute*30 {
@@ -235,22 +258,31 @@ func newConversion(appIns []*AppIn) *Conversion {
 			}
 		}
 
-		if status == "" && appIn.CompletedAt == nil {
-			duration := time.Since(appIn.CreatedAt)
-			if duration > threshold {
+		if r.pending() {
+			duration := time.Since(r.CreatedAt)
+			if duration > time.Duration(sla.rule(r.Stage, r.Product, r.CustomerType).Target) {
 				needAttention++
 			}
 		}
 
-		if strings.Contains(status, "not pass") {
+		switch r.Outcome {
+		case OutcomeRejected:
 			notPassed++
+		case OutcomeCancelled:
+			cancelled++
+		case OutcomeReturned:
+			returned++
 		}
 	}
 
 	var conversionRate, fastestPercent float32
 	var averageTime time.Duration
 
-	processed := converted + notPassed
+	processed := converted
+	if st.RejectedIsProcessed {
+		processed += notPassed
+	}
+
 	if total > 0 {
 		conversionRate = float32(processed) / float32(total) * 100
 		if processed > 0 {
@@ -272,72 +304,21 @@ func newConversion(appIns []*AppIn) *Conversion {
 		BestTime:       bestTime,
 		FastestPercent: fastestPercent,
 		NotPassed:      notPassed,
+		Cancelled:      cancelled,
+		Returned:       returned,
+		processed:      processed,
 	}
 }
 
-func newCAFinalConversion(cs []*CAFinal) *Conversion {
-	total := int64(len(cs))
-
-	var sum, bestTime time.Duration
-	var fastestCount, needAttention, converted int64
-	const threshold = 5 * time.Hour
-
-	for _, c := range cs {
-		status := strings.ToLower(c.Status)
-		if len(status) > 0 && status == "completed" && c.CompletedAt != nil {
-			converted++
-			duration := c.CompletedAt.Sub(c.CreatedAt)
-			sum += duration
-
-			if duration <= time.Minute*30 {
-				fastestCount++
-			}
-
-			if (bestTime == 0 || duration < bestTime) && duration >= time.Minute {
-				bestTime = duration
-			}
-		}
-
-		if status != "completed" && c.CompletedAt == nil {
-			duration := time.Since(c.CreatedAt)
-			if duration > threshold {
-				needAttention++
-			}
-		}
-	}
-
-	var conversionRate, fastestPercent float32
-	var averageTime time.Duration
-
-	if total > 0 {
-		conversionRate = float32(converted) / float32(total) * 100
-		if converted > 0 {
-			averageTime = sum / time.Duration(converted)
-		}
-	}
-
-	if converted > 0 {
-		fastestPercent = float32(fastestCount) / float32(converted) * 100
-	}
-
-	return &Conversion{
-		Total:          total,
-		Converted:      converted,
-		Rate:           conversionRate,
-		AverageTime:    averageTime,
-		NeedAttention:  needAttention,
-		Fastest:        fastestCount,
-		BestTime:       bestTime,
-		FastestPercent: fastestPercent,
-	}
-}
-
-// getTopPerformer returns the top performer from a slice of AppIn.
+// getTopPerformer returns the performer who converted the most, whatever the leaderboard is ranked by.
+// Ties are broken by the conversion rate, then by the display name.
 func getTopPerformer(conversions map[string]*performerMetric) *TopPerformer {
 	var top TopPerformer
 
 	for executor, c := range conversions {
-		if c.Conversion.Converted > top.Converted || (c.Conversion.Converted == top.Converted && c.Conversion.Rate > top.ConversionRate) {
+		if c.Conversion.Converted > top.Converted ||
+			(c.Conversion.Converted == top.Converted && c.Conversion.Rate > top.ConversionRate) ||
+			(c.Conversion.Converted == top.Converted && c.Conversion.Rate == top.ConversionRate && top.DisplayName != "" && executor < top.DisplayName) {
 			top.DisplayName = executor
 			top.Converted = c.Conversion.Converted
 			top.ConversionRate = c.Conversion.Rate
@@ -347,76 +328,45 @@ func getTopPerformer(conversions map[string]*performerMetric) *TopPerformer {
 	return &top
 }
 
-func groupAppInByExecutor(appIns []*AppIn) map[string][]*AppIn {
-	groups := make(map[string][]*AppIn, 0)
-	for _, a := range appIns {
-		if a.Executor == "" {
+// groupByExecutor groups records by executor.
+// Executors are resolved by the executor directory when listed, so every name of an executor is one group.
+func groupByExecutor(rs []*Record) map[string][]*Record {
+	groups := make(map[string][]*Record, 0)
+	for _, r := range rs {
+		if r.Executor == "" {
 			continue
 		}
 
-		groups[a.Executor] = append(groups[a.Executor], a)
+		groups[r.Executor] = append(groups[r.Executor], r)
 	}
 
 	return groups
 }
 
-func groupCAFinalByExecutor(appIns []*CAFinal) map[string][]*CAFinal {
-	groups := make(map[string][]*CAFinal, 0)
-	for _, a := range appIns {
-		if a.Executor == "" {
+// groupByProduct groups records by the product label of the grouping scheme.
+func groupByProduct(rs []*Record, g *GroupingScheme) map[string][]*Record {
+	groups := make(map[string][]*Record, 0)
+	for _, r := range rs {
+		key, ok := g.label(r.Product, r.CustomerType, r.Stage)
+		if !ok {
 			continue
 		}
 
-		groups[a.Executor] = append(groups[a.Executor], a)
+		groups[key] = append(groups[key], r)
 	}
 
 	return groups
 }
 
-func groupAppInByProduct(appIns []*AppIn) map[string][]*AppIn {
-	groups := make(map[string][]*AppIn, 0)
-	for _, a := range appIns {
-		if a.Type == "" {
-			continue
-		}
-
-		switch strings.ToLower(a.Product) {
-		case "sale auto":
-			switch {
-			case strings.Contains(strings.ToLower(a.Type), "c4c"):
-				key := fmt.Sprintf(`C4C | %s`, a.Product)
-				groups[key] = append(groups[key], a)
-
-			case strings.Contains(strings.ToLower(a.Type), "used car"):
-				key := fmt.Sprintf(`Used Car | %s`, a.Product)
-				groups[key] = append(groups[key], a)
-
-			case strings.Contains(strings.ToLower(a.Type), "mc"):
-				key := fmt.Sprintf(`MC | %s`, a.Product)
-				groups[key] = append(groups[key], a)
-
-			default:
-				groups[a.Product] = append(groups[a.Product], a)
-			}
-
-		default:
-			groups[a.Product] = append(groups[a.Product], a)
-		}
-
-	}
-
-	return groups
-}
-
-func calculatePerformanceConversionMetricsByProduct(appIns []*AppIn) []*ProductMetrics {
-	groups := groupAppInByProduct(appIns)
+func calculateConversionMetricsByProduct(st *StageConfig, rs []*Record, sla *SLAPolicy, g *GroupingScheme) []*ProductMetrics {
+	groups := groupByProduct(rs, g)
 	products := make([]*ProductMetrics, 0)
 
-	for product, apps := range groups {
-		c := newConversion(apps)
+	for product, items := range groups {
+		c := newConversion(st, items, sla)
 		products = append(products, &ProductMetrics{
 			Name:           product,
-			Total:          int64(len(apps)),
+			Total:          int64(len(items)),
 			Converted:      c.Converted,
 			ConversionRate: c.Rate,
 			AverageTime:    c.AverageTime,
@@ -424,6 +374,10 @@ func calculatePerformanceConversionMetricsByProduct(appIns []*AppIn) []*ProductM
 		})
 	}
 
+	return sortProductMetrics(products)
+}
+
+func sortProductMetrics(products []*ProductMetrics) []*ProductMetrics {
 	sort.Slice(products, func(i, j int) bool {
 		// Sort by Converted count (descending)
 		if products[i].Converted != products[j].Converted {
@@ -442,28 +396,27 @@ func calculatePerformanceConversionMetricsByProduct(appIns []*AppIn) []*ProductM
 	return products
 }
 
-func calculatePerformanceConversionMetricsByExecutor(groups map[string][]*AppIn) map[string]*performerMetric {
+func calculateConversionMetricsByExecutor(st *StageConfig, groups map[string][]*Record, sla *SLAPolicy) map[string]*performerMetric {
 	performers := make(map[string]*performerMetric, 0)
 
-	for executor, apps := range groups {
-		performers[executor] = &performerMetric{
-			DisplayName:  executor,
-			Conversion:   newConversion(apps),
-			Performances: createTimeIntervalsByConverted(apps),
-		}
-	}
+	for executor, rs := range groups {
+		durations := sortDurations(convertedDurations(rs))
 
-	return performers
-}
-
-func calculateCAFinalConversionMetricsByExecutor(groups map[string][]*CAFinal) map[string]*performerMetric {
-	performers := make(map[string]*performerMetric, 0)
+		var value Decimal
+		for _, r := range rs {
+			if r.converted() && r.Value != nil {
+				value += *r.Value
+			}
+		}
 
-	for executor, cs := range groups {
 		performers[executor] = &performerMetric{
-			DisplayName:  executor,
-			Conversion:   newCAFinalConversion(cs),
-			Performances: createCAFinalTimeIntervalsByConverted(cs),
+			DisplayName:   executor,
+			Conversion:    newConversion(st, rs, sla),
+			Performances:  createTimeIntervalsByConverted(rs, url.Values{"executor": {executor}}),
+			P50:           percentile(durations, 50),
+			P90:           percentile(durations, 90),
+			SLACompliance: newSLAReport(rs, sla).Compliance,
+			ValueFinanced: value,
 		}
 	}
 
@@ -471,154 +424,38 @@ func calculateCAFinalConversionMetricsByExecutor(groups map[string][]*CAFinal) m
 }
 
 type performerMetric struct {
-	DisplayName  string
-	Conversion   *Conversion
-	Performances []*TimeInterval
+	DisplayName   string
+	Conversion    *Conversion
+	Performances  []*TimeInterval
+	P50           time.Duration
+	P90           time.Duration
+	SLACompliance float32
+	ValueFinanced Decimal
+
+	// Interval and AdjustedRate are set when ranked. See setConfidence.
+	Interval     *RateInterval
+	AdjustedRate float32
+
+	// Score is set when ranked. See setScores.
+	Score *Score
 }
 
-// createLeaderboards creates a leaderboard from the conversion metrics. And sorts it by conversion number and rate.
-// Will return the top 5 performers.
-func createLeaderboards(conversions map[string]*performerMetric) []*Leaderboard {
-	performers := make([]*performerMetric, 0, len(conversions))
-	for _, c := range conversions {
-		performers = append(performers, c)
-	}
-
-	sort.Slice(performers, func(i, j int) bool {
-		// Sort by Converted count (descending)
-		if performers[i].Conversion.Converted != performers[j].Conversion.Converted {
-			return performers[i].Conversion.Converted > performers[j].Conversion.Converted
-		}
-
-		// If Converted count is the same, sort by Conversion rate (descending)
-		if performers[i].Conversion.Rate != performers[j].Conversion.Rate {
-			return performers[i].Conversion.Rate > performers[j].Conversion.Rate
-		}
-
-		// If rates are also the same, sort by average time (ascending)
-		return performers[i].Conversion.AverageTime < performers[j].Conversion.AverageTime
-	})
-
-	leaderboards := make([]*Leaderboard, 0, 5)
-	for i, p := range performers {
-		leaderboards = append(leaderboards, &Leaderboard{
-			Rank:           int64(i + 1),
-			DisplayName:    p.DisplayName,
-			Converted:      p.Conversion.Converted,
-			Total:          p.Conversion.Total,
-			ConversionRate: p.Conversion.Rate,
-			Performances:   p.Performances,
-			AverageTime:    p.Conversion.AverageTime,
-			BestTime:       p.Conversion.BestTime,
-		})
-
-		// Limit to top 5
-		if len(leaderboards) >= 5 {
-			break
-		}
-	}
-
-	return leaderboards
-}
-
-func createTimeIntervalsByConverted(appIns []*AppIn) []*TimeInterval {
-	titles := []string{
-		"<30min",
-		"<1h",
-		"<2h",
-		"<3h",
-		"<4h",
-		"<5h",
-		"5h+",
-	}
-
-	intervals := make(map[string]int64)
-	for _, t := range titles {
-		intervals[t] = 0
-	}
-
-	for _, a := range appIns {
-		status := strings.ToLower(a.Status)
-		if len(a.Status) > 0 && !strings.Contains(status, "not pass") && a.CompletedAt != nil {
-			duration := a.CompletedAt.Sub(a.CreatedAt)
-			switch {
-			case duration < 30*time.Minute:
-				intervals["<30min"]++
-			case duration < time.Hour:
-				intervals["<1h"]++
-			case duration < 2*time.Hour:
-				intervals["<2h"]++
-			case duration < 3*time.Hour:
-				intervals["<3h"]++
-			case duration < 4*time.Hour:
-				intervals["<4h"]++
-			case duration < 5*time.Hour:
-				intervals["<5h"]++
-			default:
-				intervals["5h+"]++
-			}
-		}
-	}
-
-	ts := make([]*TimeInterval, 0)
-	for _, t := range titles {
-		ts = append(ts, &TimeInterval{
-			Title: t,
-			Total: intervals[t],
-		})
-	}
-
-	return ts
+// createTimeIntervalsByConverted counts the converted records by turnaround bucket.
+// filters is added to the drill-down of each bucket.
+func createTimeIntervalsByConverted(rs []*Record, filters url.Values) []*TimeInterval {
+	return newTimeIntervals(ConvertedBucketParam, filters, convertedDurations(rs))
 }
 
-func createTimeIntervalsByPending(appIns []*AppIn) []*TimeInterval {
-	titles := []string{
-		"<30min",
-		"<1h",
-		"<2h",
-		"<3h",
-		"<4h",
-		"<5h",
-		"5h+",
-	}
-
-	intervals := make(map[string]int64)
-	for _, t := range titles {
-		intervals[t] = 0
-	}
-
+func createTimeIntervalsByPending(rs []*Record) []*TimeInterval {
 	now := time.Now()
-	for _, a := range appIns {
-		if a.Status == "" && a.CompletedAt == nil {
-			duration := now.Sub(a.CreatedAt)
-			switch {
-			case duration < 30*time.Minute:
-				intervals["<30min"]++
-			case duration < time.Hour:
-				intervals["<1h"]++
-			case duration < 2*time.Hour:
-				intervals["<2h"]++
-			case duration < 3*time.Hour:
-				intervals["<3h"]++
-			case duration < 4*time.Hour:
-				intervals["<4h"]++
-			case duration < 5*time.Hour:
-				intervals["<5h"]++
-			default:
-				intervals["5h+"]++
-			}
+	durations := make([]time.Duration, 0)
+	for _, r := range rs {
+		if r.pending() {
+			durations = append(durations, now.Sub(r.CreatedAt))
 		}
 	}
 
-	ts := make([]*TimeInterval, 0)
-	for _, t := range titles {
-		ts = append(ts, &TimeInterval{
-			Title: t,
-			Total: intervals[t],
-		})
-	}
-
-	return ts
+	return newTimeIntervals(PendingBucketParam, nil, durations)
 }
 
 func findBestTimeUsedByExecutor(p map[string]*performerMetric) *BestTimeExecutor {
@@ -646,103 +483,3 @@ func findBestTimeUsedByExecutor(p map[string]*performerMetric) *BestTimeExecutor
 		BestTime:    bestTime,
 	}
 }
-
-func createCAFinalTimeIntervalsByConverted(cs []*CAFinal) []*TimeInterval {
-	titles := []string{
-		"<30min",
-		"<1h",
-		"<2h",
-		"<3h",
-		"<4h",
-		"<5h",
-		"5h+",
-	}
-
-	intervals := make(map[string]int64)
-	for _, t := range titles {
-		intervals[t] = 0
-	}
-
-	for _, a := range cs {
-		status := strings.ToLower(a.Status)
-		if status == "completed" && a.CompletedAt != nil {
-			duration := a.CompletedAt.Sub(a.CreatedAt)
-			switch {
-			case duration < 30*time.Minute:
-				intervals["<30min"]++
-			case duration < time.Hour:
-				intervals["<1h"]++
-			case duration < 2*time.Hour:
-				intervals["<2h"]++
-			case duration < 3*time.Hour:
-				intervals["<3h"]++
-			case duration < 4*time.Hour:
-				intervals["<4h"]++
-			case duration < 5*time.Hour:
-				intervals["<5h"]++
-			default:
-				intervals["5h+"]++
-			}
-		}
-	}
-
-	ts := make([]*TimeInterval, 0)
-	for _, t := range titles {
-		ts = append(ts, &TimeInterval{
-			Title: t,
-			Total: intervals[t],
-		})
-	}
-
-	return ts
-}
-
-func createCAFinalTimeIntervalsByPending(cs []*CAFinal) []*TimeInterval {
-	titles := []string{
-		"<30min",
-		"<1h",
-		"<2h",
-		"<3h",
-		"<4h",
-		"<5h",
-		"5h+",
-	}
-
-	intervals := make(map[string]int64)
-	for _, t := range titles {
-		intervals[t] = 0
-	}
-
-	now := time.Now()
-	for _, a := range cs {
-		if strings.ToLower(a.Status) != "completed" && a.CompletedAt == nil {
-			duration := now.Sub(a.CreatedAt)
-			switch {
-			case duration < 30*time.Minute:
-				intervals["<30min"]++
-			case duration < time.Hour:
-				intervals["<1h"]++
-			case duration < 2*time.Hour:
-				intervals["<2h"]++
-			case duration < 3*time.Hour:
-				intervals["<3h"]++
-			case duration < 4*time.Hour:
-				intervals["<4h"]++
-			case duration < 5*time.Hour:
-				intervals["<5h"]++
-			default:
-				intervals["5h+"]++
-			}
-		}
-	}
-
-	ts := make([]*TimeInterval, 0)
-	for _, t := range titles {
-		ts = append(ts, &TimeInterval{
-			Title: t,
-			Total: intervals[t],
-		})
-	}
-
-	return ts
-}
@@ -0,0 +1,352 @@
+package appin
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"time"
+)
+
+// Aggregate is how the values of the items matching a custom metric are combined.
+type Aggregate string
+
+const (
+	// AggregateCount counts the matching items.
+	AggregateCount Aggregate = "count"
+
+	// AggregateSum sums the value of the matching items.
+	AggregateSum Aggregate = "sum"
+
+	// AggregateAvg averages the value of the matching items.
+	AggregateAvg Aggregate = "avg"
+
+	// AggregateMin is the lowest value of the matching items.
+	AggregateMin Aggregate = "min"
+
+	// AggregateMax is the highest value of the matching items.
+	AggregateMax Aggregate = "max"
+
+	// AggregatePercentile is the Percentile-th percentile of the value of the matching items.
+	AggregatePercentile Aggregate = "percentile"
+
+	// AggregateRatio is the percentage of the items matching Over that also match Filter.
+	AggregateRatio Aggregate = "ratio"
+)
+
+func (a Aggregate) needsValue() bool {
+	switch a {
+	case AggregateSum, AggregateAvg, AggregateMin, AggregateMax, AggregatePercentile:
+		return true
+	default:
+		return false
+	}
+}
+
+// CustomMetrics is the user-defined metrics of the overview.
+type CustomMetrics []*CustomMetric
+
+// CustomMetric is a user-defined metric.
+// Expressions are written in the expression language described in expr.go.
+//
+// ex: the not-passed share of corporate customers
+//
+//	{
+//	  "id": "corporateNotPassedShare",
+//	  "stage": "appin",
+//	  "over": "lower(customerType) == \"corporate\"",
+//	  "filter": "outcome == \"rejected\"",
+//	  "aggregate": "ratio"
+//	}
+type CustomMetric struct {
+	// ID is the unique ID of the metric.
+	ID string `json:"id"`
+
+	// Name is the display name of the metric.
+	Name string `json:"name"`
+
+	// Stage is the stage the metric is calculated from.
+	Stage Stage `json:"stage"`
+
+	// Filter is a bool expression selecting the items of the metric. Every item if empty.
+	Filter string `json:"filter"`
+
+	// Aggregate is how the matching items are combined.
+	Aggregate Aggregate `json:"aggregate"`
+
+	// Value is a number expression aggregated for each matching item.
+	// Required by sum, avg, min, max and percentile. Items with a null value are skipped.
+	Value string `json:"value"`
+
+	// Percentile is the percentile from 0 to 100 of the percentile aggregate.
+	Percentile float64 `json:"percentile"`
+
+	// Over is a bool expression selecting the denominator of the ratio aggregate. Every item if empty.
+	Over string `json:"over"`
+
+	appIn   *compiledMetric[*AppIn]
+	caFinal *compiledMetric[*CAFinal]
+}
+
+// CustomMetricValue is the value of a custom metric.
+type CustomMetricValue struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Stage     Stage     `json:"stage"`
+	Aggregate Aggregate `json:"aggregate"`
+
+	// Value is the aggregated value. Nil if no item was aggregated.
+	Value *float64 `json:"value"`
+
+	// Count is the number of items aggregated.
+	Count int64 `json:"count"`
+}
+
+// ReadCustomMetricsFile reads custom metrics from a JSON file.
+func ReadCustomMetricsFile(name string) (CustomMetrics, error) {
+	byt, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read custom metrics: %w", err)
+	}
+
+	var ms CustomMetrics
+	if err := json.Unmarshal(byt, &ms); err != nil {
+		return nil, fmt.Errorf("failed to parse custom metrics: %w", err)
+	}
+
+	return ms, ms.Validate()
+}
+
+// Validate checks and compiles the expressions of every custom metric.
+func (ms CustomMetrics) Validate() error {
+	ids := make(map[string]bool, len(ms))
+	for _, m := range ms {
+		if m.ID == "" {
+			return fmt.Errorf("custom metric id is empty")
+		}
+		if ids[m.ID] {
+			return fmt.Errorf("custom metric %q is duplicated", m.ID)
+		}
+		ids[m.ID] = true
+
+		if err := m.compile(); err != nil {
+			return fmt.Errorf("custom metric %q: %w", m.ID, err)
+		}
+	}
+
+	return nil
+}
+
+func (m *CustomMetric) compile() error {
+	switch m.Aggregate {
+	case AggregateCount, AggregateSum, AggregateAvg, AggregateMin, AggregateMax, AggregateRatio:
+	case AggregatePercentile:
+		if m.Percentile <= 0 || m.Percentile > 100 {
+			return fmt.Errorf("percentile must be greater than 0 and at most 100")
+		}
+	default:
+		return fmt.Errorf("unknown aggregate %q", m.Aggregate)
+	}
+
+	if m.Aggregate.needsValue() && m.Value == "" {
+		return fmt.Errorf("aggregate %s needs a value", m.Aggregate)
+	}
+
+	var err error
+	switch m.Stage {
+	case StageAppIn:
+		m.appIn, err = compileMetric(m, appInFields)
+	case StageCAFinal:
+		m.caFinal, err = compileMetric(m, caFinalFields)
+	default:
+		err = fmt.Errorf("unknown stage %q", m.Stage)
+	}
+
+	return err
+}
+
+// evaluate returns the value of every custom metric.
+func (ms CustomMetrics) evaluate(as []*AppIn, ca []*CAFinal) []*CustomMetricValue {
+	vs := make([]*CustomMetricValue, 0, len(ms))
+	for _, m := range ms {
+		v := &CustomMetricValue{
+			ID:        m.ID,
+			Name:      m.Name,
+			Stage:     m.Stage,
+			Aggregate: m.Aggregate,
+		}
+
+		switch m.Stage {
+		case StageAppIn:
+			v.Value, v.Count = m.appIn.evaluate(m, as)
+		case StageCAFinal:
+			v.Value, v.Count = m.caFinal.evaluate(m, ca)
+		}
+
+		vs = append(vs, v)
+	}
+
+	return vs
+}
+
+// compiledMetric is the compiled expressions of a custom metric. A nil expression is not set.
+type compiledMetric[T any] struct {
+	filter exprNode[T]
+	over   exprNode[T]
+	value  exprNode[T]
+}
+
+func compileMetric[T any](m *CustomMetric, fields map[string]*exprField[T]) (*compiledMetric[T], error) {
+	c := new(compiledMetric[T])
+
+	var err error
+	if m.Filter != "" {
+		if c.filter, err = compileExpr(m.Filter, fields, exprBool); err != nil {
+			return nil, fmt.Errorf("invalid filter: %w", err)
+		}
+	}
+	if m.Over != "" {
+		if c.over, err = compileExpr(m.Over, fields, exprBool); err != nil {
+			return nil, fmt.Errorf("invalid over: %w", err)
+		}
+	}
+	if m.Value != "" {
+		if c.value, err = compileExpr(m.Value, fields, exprNumber); err != nil {
+			return nil, fmt.Errorf("invalid value: %w", err)
+		}
+	}
+
+	return c, nil
+}
+
+func (c *compiledMetric[T]) evaluate(m *CustomMetric, items []T) (*float64, int64) {
+	matches := func(node exprNode[T], item T) bool {
+		return node == nil || node(item).b
+	}
+
+	if m.Aggregate == AggregateRatio {
+		var matched, total int64
+		for _, item := range items {
+			if !matches(c.over, item) {
+				continue
+			}
+			total++
+			if matches(c.filter, item) {
+				matched++
+			}
+		}
+		if total == 0 {
+			return nil, 0
+		}
+
+		v := float64(matched) / float64(total) * 100
+		return &v, total
+	}
+
+	values := make([]float64, 0)
+	for _, item := range items {
+		if !matches(c.filter, item) {
+			continue
+		}
+		if c.value == nil {
+			values = append(values, 0)
+			continue
+		}
+		if x := c.value(item); x.valid {
+			values = append(values, x.n)
+		}
+	}
+
+	count := int64(len(values))
+	if m.Aggregate == AggregateCount {
+		v := float64(count)
+		return &v, count
+	}
+	if count == 0 {
+		return nil, 0
+	}
+
+	var v float64
+	switch m.Aggregate {
+	case AggregateSum, AggregateAvg:
+		for _, x := range values {
+			v += x
+		}
+		if m.Aggregate == AggregateAvg {
+			v /= float64(count)
+		}
+	case AggregateMin, AggregateMax, AggregatePercentile:
+		sort.Float64s(values)
+		switch m.Aggregate {
+		case AggregateMin:
+			v = values[0]
+		case AggregateMax:
+			v = values[count-1]
+		default:
+			rank := int(math.Ceil(m.Percentile / 100 * float64(count)))
+			v = values[max(rank, 1)-1]
+		}
+	}
+
+	return &v, count
+}
+
+// minutes returns the duration in minutes as an expression value.
+func minutes(d time.Duration) exprValue {
+	return numberValue(d.Minutes())
+}
+
+// appInFields is the fields of App-In in the expression language.
+// Times are in minutes. turnaround is null until completed.
+var appInFields = map[string]*exprField[*AppIn]{
+	"number":       {exprString, func(a *AppIn) exprValue { return stringValue(a.Number) }},
+	"displayName":  {exprString, func(a *AppIn) exprValue { return stringValue(a.DisplayName) }},
+	"executor":     {exprString, func(a *AppIn) exprValue { return stringValue(a.Executor) }},
+	"product":      {exprString, func(a *AppIn) exprValue { return stringValue(a.Product) }},
+	"customerType": {exprString, func(a *AppIn) exprValue { return stringValue(a.Type) }},
+	"status":       {exprString, func(a *AppIn) exprValue { return stringValue(a.Status) }},
+	"outcome":      {exprString, func(a *AppIn) exprValue { return stringValue(string(a.Outcome)) }},
+	"createdBy":    {exprString, func(a *AppIn) exprValue { return stringValue(a.CreatedBy) }},
+	"completed":    {exprBool, func(a *AppIn) exprValue { return boolValue(a.CompletedAt != nil) }},
+	"age":          {exprNumber, func(a *AppIn) exprValue { return minutes(time.Since(a.CreatedAt)) }},
+	"turnaround": {exprNumber, func(a *AppIn) exprValue {
+		if a.CompletedAt == nil {
+			return exprValue{}
+		}
+		return minutes(a.CompletedAt.Sub(a.CreatedAt))
+	}},
+	"financeAmount": {exprNumber, func(a *AppIn) exprValue {
+		if a.FinanceAmountValue == nil {
+			return exprValue{}
+		}
+		return numberValue(a.FinanceAmountValue.Float64())
+	}},
+	"termMonths": {exprNumber, func(a *AppIn) exprValue {
+		if a.TermMonths == nil {
+			return exprValue{}
+		}
+		return numberValue(float64(*a.TermMonths))
+	}},
+}
+
+// caFinalFields is the fields of CA Final in the expression language.
+// Times are in minutes. turnaround is null until completed.
+var caFinalFields = map[string]*exprField[*CAFinal]{
+	"number":       {exprString, func(c *CAFinal) exprValue { return stringValue(c.Number) }},
+	"displayName":  {exprString, func(c *CAFinal) exprValue { return stringValue(c.DisplayName) }},
+	"executor":     {exprString, func(c *CAFinal) exprValue { return stringValue(c.Executor) }},
+	"status":       {exprString, func(c *CAFinal) exprValue { return stringValue(c.Status) }},
+	"product":      {exprString, func(c *CAFinal) exprValue { return stringValue(c.Product) }},
+	"customerType": {exprString, func(c *CAFinal) exprValue { return stringValue(c.Type) }},
+	"reason":       {exprString, func(c *CAFinal) exprValue { return stringValue(c.Reason) }},
+	"outcome":      {exprString, func(c *CAFinal) exprValue { return stringValue(string(c.Outcome)) }},
+	"completed":    {exprBool, func(c *CAFinal) exprValue { return boolValue(c.CompletedAt != nil) }},
+	"age":          {exprNumber, func(c *CAFinal) exprValue { return minutes(time.Since(c.CreatedAt)) }},
+	"turnaround": {exprNumber, func(c *CAFinal) exprValue {
+		if c.CompletedAt == nil {
+			return exprValue{}
+		}
+		return minutes(c.CompletedAt.Sub(c.CreatedAt))
+	}},
+}
@@ -0,0 +1,167 @@
+package appin
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// decimalScale is the number of minor units of a Decimal.
+const (
+	decimalPlaces = 2
+	decimalScale  = 100
+)
+
+// Decimal is an exact amount with two decimal places, stored in minor units.
+// It is encoded in JSON as a string. ex: "1500000.50"
+type Decimal int64
+
+func (d Decimal) String() string {
+	sign := ""
+	v := int64(d)
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+
+	return fmt.Sprintf("%s%d.%0*d", sign, v/decimalScale, decimalPlaces, v%decimalScale)
+}
+
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// Float64 returns the approximate value of d.
+func (d Decimal) Float64() float64 {
+	return float64(d) / decimalScale
+}
+
+// divide returns d / n rounded half away from zero.
+func (d Decimal) divide(n int64) Decimal {
+	if n == 0 {
+		return 0
+	}
+
+	q, r := int64(d)/n, int64(d)%n
+	if 2*abs(r) >= abs(n) {
+		if (int64(d) < 0) != (n < 0) {
+			q--
+		} else {
+			q++
+		}
+	}
+
+	return Decimal(q)
+}
+
+func abs(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// amountPattern is an amount typed by a person: exactly one number of digits and separators,
+// with an optional sign and currency text around it.
+var amountPattern = regexp.MustCompile(`^[\p{L}\p{Sc}\s]*([-+]?)[\p{L}\p{Sc}\s]*([.,]?\d[\d.,]*)[\p{L}\p{Sc}\s.]*$`)
+
+// ParseDecimal parses an amount typed by a person.
+// Currency text, spaces and thousand separators are ignored. ex: "1,500,000 LAK", "₭ 1.500.000,50"
+// Text with more than one number or other punctuation is an error. ex: "2024-01-01", "5e6", "1,500 (75 USD)"
+// More than two decimal places is an error since the value would not be exact.
+func ParseDecimal(s string) (Decimal, error) {
+	m := amountPattern.FindStringSubmatch(s)
+	if m == nil {
+		return 0, fmt.Errorf("amount %q is not a single number", s)
+	}
+
+	negative := m[1] == "-"
+	num := strings.TrimRight(m[2], ".,")
+	if strings.ContainsAny(num, ".,") && hasAdjacentSeparators(num) {
+		return 0, fmt.Errorf("amount %q is not a single number", s)
+	}
+
+	intPart, fracPart := splitDecimal(num)
+	if len(fracPart) > decimalPlaces {
+		return 0, fmt.Errorf("amount %q has more than %d decimal places", s, decimalPlaces)
+	}
+	if intPart == "" {
+		intPart = "0"
+	}
+
+	frac := int64(0)
+	if fracPart != "" {
+		frac, _ = strconv.ParseInt(fracPart+strings.Repeat("0", decimalPlaces-len(fracPart)), 10, 64)
+	}
+
+	units, err := strconv.ParseInt(intPart, 10, 64)
+	if err != nil || units > (math.MaxInt64-frac)/decimalScale {
+		return 0, fmt.Errorf("amount %q is out of range", s)
+	}
+
+	v := units*decimalScale + frac
+	if negative {
+		v = -v
+	}
+
+	return Decimal(v), nil
+}
+
+// hasAdjacentSeparators reports whether two separators follow each other. ex: "1..5", "1,.5"
+func hasAdjacentSeparators(num string) bool {
+	for i := 1; i < len(num); i++ {
+		if strings.IndexByte(".,", num[i]) >= 0 && strings.IndexByte(".,", num[i-1]) >= 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// splitDecimal splits a number of digits and separators into its integer and fraction digits.
+// The last separator is the decimal separator when the other separator is also used, or when it
+// appears once and is not a group of three digits after a non-zero number. Otherwise every
+// separator groups thousands.
+func splitDecimal(num string) (string, string) {
+	last := strings.LastIndexAny(num, ".,")
+	if last < 0 {
+		return num, ""
+	}
+
+	sep := num[last]
+	other := byte(',')
+	if sep == ',' {
+		other = '.'
+	}
+
+	digitsAfter := len(num) - last - 1
+	leadingZero := strings.TrimLeft(num[:last], "0") == ""
+	isDecimal := strings.IndexByte(num, other) >= 0 ||
+		(strings.Count(num, string(sep)) == 1 && (digitsAfter != 3 || leadingZero))
+
+	if !isDecimal {
+		return stripSeparators(num), ""
+	}
+
+	return stripSeparators(num[:last]), num[last+1:]
+}
+
+func stripSeparators(s string) string {
+	return strings.NewReplacer(",", "", ".", "").Replace(s)
+}
+
+// ParseTerm parses a number of instalments typed by a person. ex: "36", "36 months", "36.0"
+func ParseTerm(s string) (int64, error) {
+	d, err := ParseDecimal(s)
+	if err != nil {
+		return 0, fmt.Errorf("term %q is not a number", s)
+	}
+	if d%decimalScale != 0 || d < 0 {
+		return 0, fmt.Errorf("term %q is not a whole positive number", s)
+	}
+
+	return int64(d / decimalScale), nil
+}
@@ -0,0 +1,89 @@
+package appin
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Decimal
+		wantErr bool
+	}{
+		{in: "1500000", want: 150000000},
+		{in: "1,500,000 LAK", want: 150000000},
+		{in: "₭ 1.500.000,50", want: 150000050},
+		{in: "LAK 1,500,000.5", want: 150000050},
+		{in: "0.5", want: 50},
+		{in: ".5", want: 50},
+		{in: "1,5", want: 150},
+		{in: "1,500", want: 150000},
+		{in: "0,500", wantErr: true},
+		{in: "-1,500.25", want: -150025},
+		{in: "LAK -200", want: -20000},
+		{in: "+200 USD", want: 20000},
+		{in: " 36 months ", want: 3600},
+		{in: "1,500.", want: 150000},
+		{in: "", wantErr: true},
+		{in: "LAK", wantErr: true},
+		{in: "1.234", want: 123400},
+		{in: "1.255,999", wantErr: true},
+		{in: "1,500,000 LAK (approx 75 USD)", wantErr: true},
+		{in: "2024-01-01", wantErr: true},
+		{in: "5e6", wantErr: true},
+		{in: "1 500", wantErr: true},
+		{in: "1..5", wantErr: true},
+		{in: "1500-", wantErr: true},
+		{in: "--1500", wantErr: true},
+		{in: "99999999999999999999", wantErr: true},
+		{in: "92233720368547758.07", want: math.MaxInt64},
+		{in: "92233720368547758.08", wantErr: true},
+		{in: "92233720368547758.99", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseDecimal(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseDecimal(%q) = %s, want error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseDecimal(%q) error = %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseDecimal(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseTerm(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{in: "36", want: 36},
+		{in: "36 months", want: 36},
+		{in: "36.0", want: 36},
+		{in: "36.5", wantErr: true},
+		{in: "-12", wantErr: true},
+		{in: "12-24", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseTerm(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseTerm(%q) = %d, want error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseTerm(%q) = %d, %v, want %d", tt.in, got, err, tt.want)
+		}
+	}
+}
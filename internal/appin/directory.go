@@ -0,0 +1,399 @@
+package appin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/10664kls/app-in-performance-api/internal/atomicfile"
+	"google.golang.org/grpc/codes"
+	rpcstatus "google.golang.org/grpc/status"
+)
+
+// maxUnassignedExecutors is the maximum number of unassigned executor names the directory remembers.
+const maxUnassignedExecutors = 10000
+
+// ExecutorIdentity is a person who executes App-In or CA Final under one or more raw names.
+//
+// ex: an executor whose display name changed and who is sometimes looked up by email
+//
+//	{
+//	  "id": "somchai.k",
+//	  "displayName": "Somchai K.",
+//	  "aliases": ["Somchai Kittisak", "somchai.k@example.com"]
+//	}
+type ExecutorIdentity struct {
+	// ID is the canonical ID of the executor.
+	ID string `json:"id"`
+
+	// DisplayName is the name the executor is grouped and reported under.
+	DisplayName string `json:"displayName"`
+
+	// Aliases is the other raw names of the executor. ex: a previous display name or an email
+	Aliases []string `json:"aliases"`
+}
+
+func (e *ExecutorIdentity) clone() *ExecutorIdentity {
+	c := *e
+	c.Aliases = slices.Clone(e.Aliases)
+	return &c
+}
+
+// names returns the ID, display name and aliases of the executor.
+func (e *ExecutorIdentity) names() []string {
+	return append([]string{e.ID, e.DisplayName}, e.Aliases...)
+}
+
+// executorKey returns the key raw executor names are matched on.
+// Names are matched case-insensitively with their whitespace collapsed.
+func executorKey(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// ExecutorDirectory maps the raw executor names of the lists to canonical executors.
+// Names of no executor of the directory are kept as they are, with their whitespace collapsed.
+type ExecutorDirectory struct {
+	// name is the file the directory is stored in. Not stored if empty.
+	name string
+
+	mu         sync.RWMutex
+	identities map[string]*ExecutorIdentity
+	keys       map[string]string
+	unassigned map[string]string
+}
+
+// NewExecutorDirectory returns the directory stored in the JSON file.
+// The file is created on the first change if it does not exist. The directory is not stored if name is empty.
+func NewExecutorDirectory(name string) (*ExecutorDirectory, error) {
+	d := &ExecutorDirectory{
+		name:       name,
+		identities: make(map[string]*ExecutorIdentity),
+		unassigned: make(map[string]string),
+	}
+
+	if name != "" {
+		byt, err := os.ReadFile(name)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read executor directory: %w", err)
+		}
+		if err == nil {
+			var ids []*ExecutorIdentity
+			if err := json.Unmarshal(byt, &ids); err != nil {
+				return nil, fmt.Errorf("failed to parse executor directory: %w", err)
+			}
+			for _, e := range ids {
+				d.identities[e.ID] = e
+			}
+		}
+	}
+
+	if err := d.index(); err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// index rebuilds the keys of the names of every executor.
+// It fails if a name belongs to more than one executor.
+func (d *ExecutorDirectory) index() error {
+	keys := make(map[string]string)
+	for _, e := range d.identities {
+		for _, n := range e.names() {
+			k := executorKey(n)
+			if k == "" {
+				continue
+			}
+			if id, ok := keys[k]; ok && id != e.ID {
+				return rpcstatus.Error(codes.AlreadyExists, fmt.Sprintf("Name %q belongs to executors %s and %s.", n, id, e.ID))
+			}
+			keys[k] = e.ID
+		}
+	}
+
+	d.keys = keys
+	for k := range d.unassigned {
+		if _, ok := keys[k]; ok {
+			delete(d.unassigned, k)
+		}
+	}
+
+	return nil
+}
+
+// resolve returns the display name of the executor of the raw name of a list item.
+// A name of no executor is returned with its whitespace collapsed and remembered as unassigned,
+// and the first spelling seen of it is returned for the names differing only by case.
+func (d *ExecutorDirectory) resolve(raw string) string {
+	name, ok := d.lookup(raw)
+	if ok || d == nil || name == "" {
+		return name
+	}
+
+	k := executorKey(name)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if n, seen := d.unassigned[k]; seen {
+		return n
+	}
+	if len(d.unassigned) < maxUnassignedExecutors {
+		d.unassigned[k] = name
+	}
+
+	return name
+}
+
+// lookup returns the display name of the executor of a name and whether the name belongs to an executor.
+// The spelling of an unassigned name is returned if it was seen. Unlike resolve it does not remember
+// unknown names, so it is used for the names of queries.
+func (d *ExecutorDirectory) lookup(raw string) (string, bool) {
+	name := strings.Join(strings.Fields(raw), " ")
+	if d == nil || name == "" {
+		return name, false
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	k := executorKey(name)
+	if id, ok := d.keys[k]; ok {
+		return d.identities[id].DisplayName, true
+	}
+	if n, seen := d.unassigned[k]; seen {
+		return n, false
+	}
+
+	return name, false
+}
+
+type ListExecutorIdentitiesResult struct {
+	// Identities is the executors of the directory by ID.
+	Identities []*ExecutorIdentity `json:"identities"`
+
+	// Unassigned is the raw names seen in the lists that belong to no executor of the directory, sorted.
+	Unassigned []string `json:"unassigned"`
+}
+
+// ListExecutorIdentities lists the executors of the directory and the names seen that belong to none.
+func (d *ExecutorDirectory) ListExecutorIdentities(_ context.Context) *ListExecutorIdentitiesResult {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	ids := make([]*ExecutorIdentity, 0, len(d.identities))
+	for _, e := range d.identities {
+		ids = append(ids, e.clone())
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].ID < ids[j].ID
+	})
+
+	unassigned := make([]string, 0, len(d.unassigned))
+	for _, n := range d.unassigned {
+		unassigned = append(unassigned, n)
+	}
+	sort.Strings(unassigned)
+
+	return &ListExecutorIdentitiesResult{
+		Identities: ids,
+		Unassigned: unassigned,
+	}
+}
+
+type ExecutorIdentityRequest struct {
+	DisplayName string   `json:"displayName"`
+	Aliases     []string `json:"aliases"`
+}
+
+// PutExecutorIdentity creates or replaces the executor of the ID.
+func (d *ExecutorDirectory) PutExecutorIdentity(_ context.Context, id string, req *ExecutorIdentityRequest) (*ExecutorIdentity, error) {
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return nil, rpcstatus.Error(codes.InvalidArgument, "id must not be empty.")
+	}
+
+	displayName := strings.Join(strings.Fields(req.DisplayName), " ")
+	if displayName == "" {
+		return nil, rpcstatus.Error(codes.InvalidArgument, "displayName must not be empty.")
+	}
+
+	e := &ExecutorIdentity{
+		ID:          id,
+		DisplayName: displayName,
+		Aliases:     cleanAliases(req.Aliases),
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	previous := d.snapshot()
+	d.identities[id] = e
+	if err := d.commit(); err != nil {
+		d.restore(previous)
+		return nil, err
+	}
+
+	return e.clone(), nil
+}
+
+// DeleteExecutorIdentity deletes the executor of the ID. Its names are no longer merged.
+func (d *ExecutorDirectory) DeleteExecutorIdentity(_ context.Context, id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.identities[id]; !ok {
+		return rpcstatus.Error(codes.NotFound, "Executor not found.")
+	}
+
+	previous := d.snapshot()
+	delete(d.identities, id)
+	if err := d.commit(); err != nil {
+		d.restore(previous)
+		return err
+	}
+
+	return nil
+}
+
+type MergeExecutorsRequest struct {
+	// Into is the ID of the executor the names are merged into.
+	Into string `json:"into"`
+
+	// DisplayName is the display name of the executor. Required if the executor does not exist.
+	// The display name is kept if empty.
+	DisplayName string `json:"displayName"`
+
+	// Names is the raw names and the IDs of executors merged into the executor.
+	// Merged executors are deleted and their names moved to the executor.
+	Names []string `json:"names"`
+}
+
+// MergeExecutors merges raw names and other executors into an executor.
+func (d *ExecutorDirectory) MergeExecutors(_ context.Context, req *MergeExecutorsRequest) (*ExecutorIdentity, error) {
+	into := strings.TrimSpace(req.Into)
+	if into == "" {
+		return nil, rpcstatus.Error(codes.InvalidArgument, "into must not be empty.")
+	}
+	if len(req.Names) == 0 {
+		return nil, rpcstatus.Error(codes.InvalidArgument, "names must not be empty.")
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	previous := d.snapshot()
+
+	e, ok := d.identities[into]
+	if ok {
+		e = e.clone()
+	} else {
+		e = &ExecutorIdentity{ID: into, Aliases: make([]string, 0)}
+	}
+	if name := strings.Join(strings.Fields(req.DisplayName), " "); name != "" {
+		e.DisplayName = name
+	}
+	if e.DisplayName == "" {
+		return nil, rpcstatus.Error(codes.InvalidArgument, "displayName is required to create an executor.")
+	}
+
+	for _, n := range cleanAliases(req.Names) {
+		k := executorKey(n)
+		id, ok := d.keys[k]
+		if !ok || id == into {
+			e.Aliases = append(e.Aliases, n)
+			continue
+		}
+
+		// The ID or display name of another executor merges it whole, an alias of another executor is moved.
+		other := d.identities[id].clone()
+		if k == executorKey(other.ID) || k == executorKey(other.DisplayName) {
+			delete(d.identities, id)
+			e.Aliases = append(e.Aliases, other.names()...)
+			continue
+		}
+
+		other.Aliases = slices.DeleteFunc(other.Aliases, func(a string) bool {
+			return executorKey(a) == k
+		})
+		d.identities[id] = other
+		e.Aliases = append(e.Aliases, n)
+	}
+	e.Aliases = slices.DeleteFunc(cleanAliases(e.Aliases), func(a string) bool {
+		return executorKey(a) == executorKey(e.ID) || executorKey(a) == executorKey(e.DisplayName)
+	})
+
+	d.identities[into] = e
+	if err := d.commit(); err != nil {
+		d.restore(previous)
+		return nil, err
+	}
+
+	return e.clone(), nil
+}
+
+// cleanAliases returns the names with their whitespace collapsed, without empty and duplicated names.
+func cleanAliases(names []string) []string {
+	aliases := make([]string, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, n := range names {
+		n = strings.Join(strings.Fields(n), " ")
+		if k := executorKey(n); k != "" && !seen[k] {
+			seen[k] = true
+			aliases = append(aliases, n)
+		}
+	}
+
+	return aliases
+}
+
+// snapshot returns a copy of the executors to restore if a change fails.
+func (d *ExecutorDirectory) snapshot() map[string]*ExecutorIdentity {
+	ids := make(map[string]*ExecutorIdentity, len(d.identities))
+	for id, e := range d.identities {
+		ids[id] = e
+	}
+
+	return ids
+}
+
+// restore restores the executors of a snapshot. The snapshot was indexed before so it cannot fail.
+func (d *ExecutorDirectory) restore(ids map[string]*ExecutorIdentity) {
+	d.identities = ids
+	_ = d.index()
+}
+
+// commit indexes the executors and writes them to the file.
+// The caller restores the executors if it fails.
+func (d *ExecutorDirectory) commit() error {
+	if err := d.index(); err != nil {
+		return err
+	}
+	if d.name == "" {
+		return nil
+	}
+
+	ids := make([]*ExecutorIdentity, 0, len(d.identities))
+	for _, e := range d.identities {
+		ids = append(ids, e)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].ID < ids[j].ID
+	})
+
+	byt, err := json.MarshalIndent(ids, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal executor directory: %w", err)
+	}
+
+	if err := atomicfile.WriteFile(d.name, byt); err != nil {
+		return fmt.Errorf("failed to save executor directory: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package appin
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMergeExecutors(t *testing.T) {
+	somchai := &ExecutorIdentity{ID: "somchai.k", DisplayName: "Somchai K.", Aliases: []string{"Somchai Kittisak", "somchai.k@example.com"}}
+	noy := &ExecutorIdentity{ID: "noy.p", DisplayName: "Noy P.", Aliases: []string{"Noy Phommachan"}}
+
+	tests := []struct {
+		name    string
+		req     *MergeExecutorsRequest
+		fail    bool
+		want    []*ExecutorIdentity
+		resolve map[string]string
+	}{
+		{
+			name: "raw names into a new executor",
+			req:  &MergeExecutorsRequest{Into: "bee.s", DisplayName: "Bee S.", Names: []string{"Bee  Souksavanh", "bee souksavanh", "Bee S."}},
+			want: []*ExecutorIdentity{
+				{ID: "bee.s", DisplayName: "Bee S.", Aliases: []string{"Bee Souksavanh"}},
+				somchai,
+				noy,
+			},
+			resolve: map[string]string{"BEE SOUKSAVANH": "Bee S.", "Somchai Kittisak": "Somchai K."},
+		},
+		{
+			name: "whole executor by its ID",
+			req:  &MergeExecutorsRequest{Into: "somchai.k", Names: []string{"noy.p"}},
+			want: []*ExecutorIdentity{
+				{ID: "somchai.k", DisplayName: "Somchai K.", Aliases: []string{"Somchai Kittisak", "somchai.k@example.com", "noy.p", "Noy P.", "Noy Phommachan"}},
+			},
+			resolve: map[string]string{"Noy P.": "Somchai K.", "noy phommachan": "Somchai K."},
+		},
+		{
+			name: "whole executor by its display name",
+			req:  &MergeExecutorsRequest{Into: "noy.p", DisplayName: "Noy Phommachan", Names: []string{"Somchai K."}},
+			want: []*ExecutorIdentity{
+				{ID: "noy.p", DisplayName: "Noy Phommachan", Aliases: []string{"somchai.k", "Somchai K.", "Somchai Kittisak", "somchai.k@example.com"}},
+			},
+			resolve: map[string]string{"Noy P.": "Noy P.", "Somchai Kittisak": "Noy Phommachan"},
+		},
+		{
+			name: "alias moved from another executor",
+			req:  &MergeExecutorsRequest{Into: "noy.p", Names: []string{"SOMCHAI.K@example.com"}},
+			want: []*ExecutorIdentity{
+				{ID: "somchai.k", DisplayName: "Somchai K.", Aliases: []string{"Somchai Kittisak"}},
+				{ID: "noy.p", DisplayName: "Noy P.", Aliases: []string{"Noy Phommachan", "SOMCHAI.K@example.com"}},
+			},
+			resolve: map[string]string{"somchai.k@example.com": "Noy P.", "Somchai Kittisak": "Somchai K."},
+		},
+		{
+			name:    "whole executor rolled back when the commit fails",
+			req:     &MergeExecutorsRequest{Into: "somchai.k", Names: []string{"noy.p"}},
+			fail:    true,
+			want:    []*ExecutorIdentity{somchai, noy},
+			resolve: map[string]string{"Noy Phommachan": "Noy P.", "Somchai Kittisak": "Somchai K."},
+		},
+		{
+			name:    "moved alias rolled back when the commit fails",
+			req:     &MergeExecutorsRequest{Into: "bee.s", DisplayName: "Bee S.", Names: []string{"Somchai Kittisak"}},
+			fail:    true,
+			want:    []*ExecutorIdentity{somchai, noy},
+			resolve: map[string]string{"Somchai Kittisak": "Somchai K.", "Bee S.": "Bee S."},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			d, err := NewExecutorDirectory("")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, e := range []*ExecutorIdentity{somchai, noy} {
+				if _, err := d.PutExecutorIdentity(ctx, e.ID, &ExecutorIdentityRequest{DisplayName: e.DisplayName, Aliases: e.Aliases}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.fail {
+				// The directory of the file does not exist, so the file cannot be written.
+				d.name = filepath.Join(t.TempDir(), "missing", "executors.json")
+			}
+
+			_, err = d.MergeExecutors(ctx, tt.req)
+			if tt.fail != (err != nil) {
+				t.Fatalf("MergeExecutors error = %v, want failure %t", err, tt.fail)
+			}
+
+			want := make(map[string]*ExecutorIdentity, len(tt.want))
+			for _, e := range tt.want {
+				want[e.ID] = e
+			}
+			if !reflect.DeepEqual(d.identities, want) {
+				t.Errorf("executors = %v, want %v", executorsOf(d.identities), executorsOf(want))
+			}
+			for raw, name := range tt.resolve {
+				if got, _ := d.lookup(raw); got != name {
+					t.Errorf("lookup(%q) = %q, want %q", raw, got, name)
+				}
+			}
+		})
+	}
+}
+
+// executorsOf returns the executors by ID as values to print them.
+func executorsOf(ids map[string]*ExecutorIdentity) map[string]ExecutorIdentity {
+	es := make(map[string]ExecutorIdentity, len(ids))
+	for id, e := range ids {
+		es[id] = *e
+	}
+	return es
+}
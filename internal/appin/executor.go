@@ -0,0 +1,302 @@
+package appin
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	rpcstatus "google.golang.org/grpc/status"
+)
+
+// ExecutorProfile is the performance of an executor across every stage.
+type ExecutorProfile struct {
+	// DisplayName is the display name of the executor.
+	DisplayName string `json:"displayName"`
+
+	// AppIn is the performance of the executor in App-In. Nil if the executor has no App-In.
+	AppIn *ExecutorStageProfile `json:"appIn"`
+
+	// CAFinal is the performance of the executor in CA Final. Nil if the executor has no CA Final.
+	CAFinal *ExecutorStageProfile `json:"caFinal"`
+}
+
+// ExecutorStageProfile is the performance of an executor in a stage.
+type ExecutorStageProfile struct {
+	// Conversion is the conversion metrics of the executor.
+	Conversion *Conversion `json:"conversion"`
+
+	// Percentiles is the distribution of the time used for converted items.
+	Percentiles *Percentiles `json:"percentiles"`
+
+	// ProductMix is the metrics of each product handled by the executor.
+	ProductMix []*ProductMetrics `json:"productMix"`
+
+	// Pending is the items waiting on the executor, the oldest first.
+	Pending []*PendingItem `json:"pending"`
+
+	// Daily is the daily performance of the executor, the earliest first.
+	Daily []*DailyPerformance `json:"daily"`
+
+	// Rank is the rank of the executor among peers of the stage.
+	// Zero if the executor is not eligible for the leaderboard.
+	Rank int64 `json:"rank"`
+
+	// Peers is the number of ranked executors of the stage.
+	Peers int64 `json:"peers"`
+}
+
+// PendingItem is an item that is not completed yet.
+type PendingItem struct {
+	Number      string        `json:"number"`
+	DisplayName string        `json:"displayName"`
+	Product     string        `json:"product"`
+	Type        string        `json:"type"`
+	Status      string        `json:"status"`
+	CreatedAt   time.Time     `json:"createdAt"`
+	Age         time.Duration `json:"age"`
+}
+
+// DailyPerformance is the performance of a day.
+type DailyPerformance struct {
+	// Date is the day. ex: "2025-01-31"
+	Date string `json:"date"`
+
+	// Received is the number of items created on the day.
+	Received int64 `json:"received"`
+
+	// Converted is the number of items converted on the day.
+	Converted int64 `json:"converted"`
+
+	// AverageTime is the average time used for the items converted on the day.
+	AverageTime time.Duration `json:"averageTime"`
+}
+
+// ExecutorSummary is the summary of an executor across every stage.
+type ExecutorSummary struct {
+	DisplayName string `json:"displayName"`
+
+	// AppIn is the leaderboard entry of the executor in App-In.
+	// Nil if the executor has no App-In or is not eligible for the leaderboard.
+	AppIn *Leaderboard `json:"appIn"`
+
+	// CAFinal is the leaderboard entry of the executor in CA Final.
+	// Nil if the executor has no CA Final or is not eligible for the leaderboard.
+	CAFinal *Leaderboard `json:"caFinal"`
+}
+
+type ListExecutorsResult struct {
+	Executors []*ExecutorSummary `json:"executors"`
+}
+
+// ListExecutors lists every executor with App-In or CA Final matching the query.
+func (s *Service) ListExecutors(ctx context.Context, q *Query) (*ListExecutorsResult, error) {
+	opts, err := s.overviewOptions(q)
+	if err != nil {
+		return nil, err
+	}
+
+	as, ca, err := s.listStages(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ListExecutorsResult{
+		Executors: newExecutorSummaries(as, ca, opts),
+	}, nil
+}
+
+// GetExecutor returns the performance profile of an executor by display name or any name of the executor directory.
+func (s *Service) GetExecutor(ctx context.Context, name string, q *Query) (*ExecutorProfile, error) {
+	opts, err := s.overviewOptions(q)
+	if err != nil {
+		return nil, err
+	}
+
+	as, ca, err := s.listStages(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	name, _ = s.executors.lookup(name)
+	p := newExecutorProfile(name, as, ca, opts)
+	if p == nil {
+		return nil, rpcstatus.Error(codes.NotFound, "Executor not found.")
+	}
+
+	return p, nil
+}
+
+func newExecutorSummaries(as []*AppIn, ca []*CAFinal, opts *OverviewOptions) []*ExecutorSummary {
+	sla, lo := opts.SLA, opts.Leaderboard
+	summaries := make(map[string]*ExecutorSummary)
+	summary := func(name string) *ExecutorSummary {
+		e, ok := summaries[name]
+		if !ok {
+			e = &ExecutorSummary{DisplayName: name}
+			summaries[name] = e
+		}
+		return e
+	}
+
+	for _, p := range rankPerformers(calculateConversionMetricsByExecutor(appInStage, groupByExecutor(records(as)), sla), lo) {
+		summary(p.DisplayName).AppIn = newLeaderboard(p.Rank, p.performerMetric)
+	}
+	for _, p := range rankPerformers(calculateConversionMetricsByExecutor(caFinalStage, groupByExecutor(records(ca)), sla), lo) {
+		summary(p.DisplayName).CAFinal = newLeaderboard(p.Rank, p.performerMetric)
+	}
+
+	es := make([]*ExecutorSummary, 0, len(summaries))
+	for _, e := range summaries {
+		es = append(es, e)
+	}
+	sort.Slice(es, func(i, j int) bool {
+		return es[i].DisplayName < es[j].DisplayName
+	})
+
+	return es
+}
+
+// newExecutorProfile returns the profile of the executor of the name resolved by the executor directory.
+// It returns nil if the executor has no App-In nor CA Final.
+func newExecutorProfile(name string, as []*AppIn, ca []*CAFinal, opts *OverviewOptions) *ExecutorProfile {
+	p := &ExecutorProfile{
+		DisplayName: name,
+		AppIn:       newExecutorStageProfile(name, appInStage, records(as), opts),
+		CAFinal:     newExecutorStageProfile(name, caFinalStage, records(ca), opts),
+	}
+	if p.AppIn == nil && p.CAFinal == nil {
+		return nil
+	}
+
+	return p
+}
+
+// newExecutorStageProfile returns the profile of the executor in a stage.
+// It returns nil if the executor has no record of the stage.
+func newExecutorStageProfile(name string, st *StageConfig, rs []*Record, opts *OverviewOptions) *ExecutorStageProfile {
+	sla := opts.SLA
+	groups := groupByExecutor(rs)
+	metrics := calculateConversionMetricsByExecutor(st, groups, sla)
+	ranked := rankPerformers(metrics, opts.Leaderboard)
+
+	// Names are resolved by the executor directory, so the records of an executor share one spelling.
+	m, ok := metrics[name]
+	if !ok {
+		return nil
+	}
+
+	items := groups[name]
+	return &ExecutorStageProfile{
+		Conversion:  m.Conversion,
+		Percentiles: newPercentiles(convertedDurations(items)),
+		ProductMix:  calculateConversionMetricsByProduct(st, items, sla, opts.Grouping),
+		Pending:     pendingItems(items),
+		Daily:       dailyPerformances(items, opts.Location),
+		Rank:        rankOf(ranked, name),
+		Peers:       int64(len(ranked)),
+	}
+}
+
+// rankOf returns the rank of the executor, or zero if not ranked.
+func rankOf(ranked []*rankedPerformer, executor string) int64 {
+	for _, p := range ranked {
+		if p.DisplayName == executor {
+			return p.Rank
+		}
+	}
+
+	return 0
+}
+
+func pendingItems(rs []*Record) []*PendingItem {
+	now := time.Now()
+	items := make([]*PendingItem, 0)
+	for _, r := range rs {
+		if r.pending() {
+			items = append(items, &PendingItem{
+				Number:      r.Number,
+				DisplayName: r.DisplayName,
+				Product:     r.Product,
+				Type:        r.CustomerType,
+				Status:      r.Status,
+				CreatedAt:   r.CreatedAt,
+				Age:         now.Sub(r.CreatedAt),
+			})
+		}
+	}
+
+	return sortPendingItems(items)
+}
+
+func sortPendingItems(items []*PendingItem) []*PendingItem {
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Age > items[j].Age
+	})
+
+	return items
+}
+
+func dailyPerformances(rs []*Record, loc *time.Location) []*DailyPerformance {
+	days := newDailySeries(loc)
+	for _, r := range rs {
+		days.receive(r.CreatedAt)
+
+		if r.converted() {
+			days.convert(*r.CompletedAt, r.CompletedAt.Sub(r.CreatedAt))
+		}
+	}
+
+	return days.performances()
+}
+
+// dailySeries accumulates the performance of each day in a location.
+type dailySeries struct {
+	loc  *time.Location
+	days map[string]*DailyPerformance
+	sums map[string]time.Duration
+}
+
+func newDailySeries(loc *time.Location) *dailySeries {
+	return &dailySeries{
+		loc:  loc,
+		days: make(map[string]*DailyPerformance),
+		sums: make(map[string]time.Duration),
+	}
+}
+
+func (s *dailySeries) day(t time.Time) *DailyPerformance {
+	date := t.In(s.loc).Format(time.DateOnly)
+	d, ok := s.days[date]
+	if !ok {
+		d = &DailyPerformance{Date: date}
+		s.days[date] = d
+	}
+	return d
+}
+
+func (s *dailySeries) receive(t time.Time) {
+	s.day(t).Received++
+}
+
+func (s *dailySeries) convert(t time.Time, used time.Duration) {
+	d := s.day(t)
+	d.Converted++
+	s.sums[d.Date] += used
+}
+
+func (s *dailySeries) performances() []*DailyPerformance {
+	ps := make([]*DailyPerformance, 0, len(s.days))
+	for date, d := range s.days {
+		if d.Converted > 0 {
+			d.AverageTime = s.sums[date] / time.Duration(d.Converted)
+		}
+		ps = append(ps, d)
+	}
+
+	sort.Slice(ps, func(i, j int) bool {
+		return ps[i].Date < ps[j].Date
+	})
+
+	return ps
+}
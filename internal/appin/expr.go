@@ -0,0 +1,513 @@
+package appin
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// The expression language of custom metrics is a small, side-effect free subset of CEL.
+//
+//	literals:    "text", 'text', 12, 1.5, true, false, null
+//	fields:      see appInFields and caFinalFields
+//	operators:   || && ! == != < <= > >= + - * / and parentheses
+//	functions:   contains(s, sub), startsWith(s, prefix), endsWith(s, suffix), lower(s), upper(s), trim(s)
+//
+// Strings compare case-sensitively, use lower() to ignore the case.
+// A null operand makes arithmetic null and comparisons other than == and != false.
+// Expressions are type checked when compiled so evaluation never fails.
+
+// exprType is the static type of an expression.
+type exprType int
+
+const (
+	exprNull exprType = iota
+	exprBool
+	exprNumber
+	exprString
+)
+
+func (t exprType) String() string {
+	switch t {
+	case exprBool:
+		return "bool"
+	case exprNumber:
+		return "number"
+	case exprString:
+		return "string"
+	default:
+		return "null"
+	}
+}
+
+// exprValue is the value of an expression. Null if valid is false.
+type exprValue struct {
+	valid bool
+	b     bool
+	n     float64
+	s     string
+}
+
+func boolValue(b bool) exprValue      { return exprValue{valid: true, b: b} }
+func numberValue(n float64) exprValue { return exprValue{valid: true, n: n} }
+func stringValue(s string) exprValue  { return exprValue{valid: true, s: s} }
+
+// exprField is a field of an item an expression can read.
+type exprField[T any] struct {
+	typ   exprType
+	value func(T) exprValue
+}
+
+// exprNode is a compiled expression evaluated against an item.
+type exprNode[T any] func(T) exprValue
+
+// compileExpr compiles the expression against the fields of an item and checks it has the wanted type.
+func compileExpr[T any](src string, fields map[string]*exprField[T], want exprType) (exprNode[T], error) {
+	tokens, err := tokenizeExpr(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &exprParser[T]{tokens: tokens, fields: fields}
+	node, typ, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at %d", tok.text, tok.pos)
+	}
+	if typ != want {
+		return nil, fmt.Errorf("expression is %s, want %s", typ, want)
+	}
+
+	return node, nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokOp
+)
+
+type exprToken struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+var exprOps = []string{"||", "&&", "==", "!=", "<=", ">=", "<", ">", "!", "+", "-", "*", "/", "(", ")", ","}
+
+func tokenizeExpr(src string) ([]*exprToken, error) {
+	tokens := make([]*exprToken, 0)
+	for i := 0; i < len(src); {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+
+		case c == '"' || c == '\'':
+			var sb strings.Builder
+			j := i + 1
+			for ; j < len(src) && rune(src[j]) != c; j++ {
+				if src[j] == '\\' && j+1 < len(src) {
+					j++
+				}
+				sb.WriteByte(src[j])
+			}
+			if j >= len(src) {
+				return nil, fmt.Errorf("unterminated string at %d", i)
+			}
+			tokens = append(tokens, &exprToken{kind: tokString, text: sb.String(), pos: i})
+			i = j + 1
+
+		case unicode.IsDigit(c) || c == '.':
+			j := i
+			for j < len(src) && (unicode.IsDigit(rune(src[j])) || src[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, &exprToken{kind: tokNumber, text: src[i:j], pos: i})
+			i = j
+
+		case unicode.IsLetter(c) || c == '_':
+			j := i
+			for j < len(src) && (unicode.IsLetter(rune(src[j])) || unicode.IsDigit(rune(src[j])) || src[j] == '_') {
+				j++
+			}
+			tokens = append(tokens, &exprToken{kind: tokIdent, text: src[i:j], pos: i})
+			i = j
+
+		default:
+			op := ""
+			for _, o := range exprOps {
+				if strings.HasPrefix(src[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected %q at %d", c, i)
+			}
+			tokens = append(tokens, &exprToken{kind: tokOp, text: op, pos: i})
+			i += len(op)
+		}
+	}
+
+	return append(tokens, &exprToken{kind: tokEOF, text: "end of expression", pos: len(src)}), nil
+}
+
+// exprParser is a recursive descent parser that compiles while parsing.
+type exprParser[T any] struct {
+	tokens []*exprToken
+	i      int
+	fields map[string]*exprField[T]
+}
+
+func (p *exprParser[T]) peek() *exprToken { return p.tokens[p.i] }
+
+func (p *exprParser[T]) next() *exprToken {
+	tok := p.tokens[p.i]
+	if tok.kind != tokEOF {
+		p.i++
+	}
+	return tok
+}
+
+func (p *exprParser[T]) accept(op string) bool {
+	if tok := p.peek(); tok.kind == tokOp && tok.text == op {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *exprParser[T]) expect(op string) error {
+	if !p.accept(op) {
+		tok := p.peek()
+		return fmt.Errorf("expected %q at %d, got %q", op, tok.pos, tok.text)
+	}
+	return nil
+}
+
+func (p *exprParser[T]) parseOr() (exprNode[T], exprType, error) {
+	left, lt, err := p.parseAnd()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for p.accept("||") {
+		right, rt, err := p.parseAnd()
+		if err != nil {
+			return nil, 0, err
+		}
+		if lt != exprBool || rt != exprBool {
+			return nil, 0, fmt.Errorf("operator || needs bool operands, got %s and %s", lt, rt)
+		}
+
+		l, r := left, right
+		left = func(v T) exprValue { return boolValue(l(v).b || r(v).b) }
+	}
+
+	return left, lt, nil
+}
+
+func (p *exprParser[T]) parseAnd() (exprNode[T], exprType, error) {
+	left, lt, err := p.parseComparison()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for p.accept("&&") {
+		right, rt, err := p.parseComparison()
+		if err != nil {
+			return nil, 0, err
+		}
+		if lt != exprBool || rt != exprBool {
+			return nil, 0, fmt.Errorf("operator && needs bool operands, got %s and %s", lt, rt)
+		}
+
+		l, r := left, right
+		left = func(v T) exprValue { return boolValue(l(v).b && r(v).b) }
+	}
+
+	return left, lt, nil
+}
+
+func (p *exprParser[T]) parseComparison() (exprNode[T], exprType, error) {
+	left, lt, err := p.parseAdditive()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	tok := p.peek()
+	if tok.kind != tokOp {
+		return left, lt, nil
+	}
+
+	op := tok.text
+	switch op {
+	case "==", "!=", "<", "<=", ">", ">=":
+		p.next()
+	default:
+		return left, lt, nil
+	}
+
+	right, rt, err := p.parseAdditive()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	l, r := left, right
+	if op == "==" || op == "!=" {
+		if lt != rt && lt != exprNull && rt != exprNull {
+			return nil, 0, fmt.Errorf("operator %s cannot compare %s and %s", op, lt, rt)
+		}
+
+		negate := op == "!="
+		return func(v T) exprValue { return boolValue(equalValues(l(v), r(v)) != negate) }, exprBool, nil
+	}
+
+	if lt != rt || (lt != exprNumber && lt != exprString) {
+		return nil, 0, fmt.Errorf("operator %s cannot compare %s and %s", op, lt, rt)
+	}
+
+	return func(v T) exprValue {
+		a, b := l(v), r(v)
+		if !a.valid || !b.valid {
+			return boolValue(false)
+		}
+
+		c := strings.Compare(a.s, b.s)
+		if lt == exprNumber {
+			c = compareNumbers(a.n, b.n)
+		}
+
+		switch op {
+		case "<":
+			return boolValue(c < 0)
+		case "<=":
+			return boolValue(c <= 0)
+		case ">":
+			return boolValue(c > 0)
+		default:
+			return boolValue(c >= 0)
+		}
+	}, exprBool, nil
+}
+
+func equalValues(a, b exprValue) bool {
+	if !a.valid || !b.valid {
+		return a.valid == b.valid
+	}
+	return a.b == b.b && a.n == b.n && a.s == b.s
+}
+
+func compareNumbers(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (p *exprParser[T]) parseAdditive() (exprNode[T], exprType, error) {
+	return p.parseArithmetic(p.parseMultiplicative, "+", "-")
+}
+
+func (p *exprParser[T]) parseMultiplicative() (exprNode[T], exprType, error) {
+	return p.parseArithmetic(p.parseUnary, "*", "/")
+}
+
+// parseArithmetic parses a left-associative chain of the operators over numbers.
+func (p *exprParser[T]) parseArithmetic(operand func() (exprNode[T], exprType, error), ops ...string) (exprNode[T], exprType, error) {
+	left, lt, err := operand()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for {
+		tok := p.peek()
+		if tok.kind != tokOp || (tok.text != ops[0] && tok.text != ops[1]) {
+			return left, lt, nil
+		}
+		p.next()
+
+		right, rt, err := operand()
+		if err != nil {
+			return nil, 0, err
+		}
+		if lt != exprNumber || rt != exprNumber {
+			return nil, 0, fmt.Errorf("operator %s needs number operands, got %s and %s", tok.text, lt, rt)
+		}
+
+		l, r, op := left, right, tok.text
+		left = func(v T) exprValue {
+			a, b := l(v), r(v)
+			if !a.valid || !b.valid {
+				return exprValue{}
+			}
+
+			switch op {
+			case "+":
+				return numberValue(a.n + b.n)
+			case "-":
+				return numberValue(a.n - b.n)
+			case "*":
+				return numberValue(a.n * b.n)
+			default:
+				if b.n == 0 {
+					return exprValue{}
+				}
+				return numberValue(a.n / b.n)
+			}
+		}
+	}
+}
+
+func (p *exprParser[T]) parseUnary() (exprNode[T], exprType, error) {
+	if p.accept("!") {
+		operand, t, err := p.parseUnary()
+		if err != nil {
+			return nil, 0, err
+		}
+		if t != exprBool {
+			return nil, 0, fmt.Errorf("operator ! needs a bool operand, got %s", t)
+		}
+		return func(v T) exprValue { return boolValue(!operand(v).b) }, exprBool, nil
+	}
+
+	if p.accept("-") {
+		operand, t, err := p.parseUnary()
+		if err != nil {
+			return nil, 0, err
+		}
+		if t != exprNumber {
+			return nil, 0, fmt.Errorf("operator - needs a number operand, got %s", t)
+		}
+		return func(v T) exprValue {
+			x := operand(v)
+			if !x.valid {
+				return x
+			}
+			return numberValue(-x.n)
+		}, exprNumber, nil
+	}
+
+	return p.parsePrimary()
+}
+
+func (p *exprParser[T]) parsePrimary() (exprNode[T], exprType, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokNumber:
+		n, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid number %q at %d", tok.text, tok.pos)
+		}
+		return constant[T](numberValue(n)), exprNumber, nil
+
+	case tokString:
+		return constant[T](stringValue(tok.text)), exprString, nil
+
+	case tokIdent:
+		switch tok.text {
+		case "true", "false":
+			return constant[T](boolValue(tok.text == "true")), exprBool, nil
+		case "null":
+			return constant[T](exprValue{}), exprNull, nil
+		}
+
+		if p.accept("(") {
+			return p.parseCall(tok)
+		}
+
+		f, ok := p.fields[tok.text]
+		if !ok {
+			return nil, 0, fmt.Errorf("unknown field %q at %d", tok.text, tok.pos)
+		}
+		return f.value, f.typ, nil
+
+	case tokOp:
+		if tok.text == "(" {
+			node, t, err := p.parseOr()
+			if err != nil {
+				return nil, 0, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, 0, err
+			}
+			return node, t, nil
+		}
+	}
+
+	return nil, 0, fmt.Errorf("unexpected %q at %d", tok.text, tok.pos)
+}
+
+func constant[T any](x exprValue) exprNode[T] {
+	return func(T) exprValue { return x }
+}
+
+// exprFunctions is the functions of the expression language by name.
+// Every function takes strings. A null argument makes a bool result false and a string result null.
+var exprFunctions = map[string]struct {
+	arity  int
+	result exprType
+	call   func(args []string) exprValue
+}{
+	"contains":   {2, exprBool, func(a []string) exprValue { return boolValue(strings.Contains(a[0], a[1])) }},
+	"startsWith": {2, exprBool, func(a []string) exprValue { return boolValue(strings.HasPrefix(a[0], a[1])) }},
+	"endsWith":   {2, exprBool, func(a []string) exprValue { return boolValue(strings.HasSuffix(a[0], a[1])) }},
+	"lower":      {1, exprString, func(a []string) exprValue { return stringValue(strings.ToLower(a[0])) }},
+	"upper":      {1, exprString, func(a []string) exprValue { return stringValue(strings.ToUpper(a[0])) }},
+	"trim":       {1, exprString, func(a []string) exprValue { return stringValue(strings.TrimSpace(a[0])) }},
+}
+
+func (p *exprParser[T]) parseCall(name *exprToken) (exprNode[T], exprType, error) {
+	fn, ok := exprFunctions[name.text]
+	if !ok {
+		return nil, 0, fmt.Errorf("unknown function %q at %d", name.text, name.pos)
+	}
+
+	args := make([]exprNode[T], 0, fn.arity)
+	for !p.accept(")") {
+		if len(args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, 0, err
+			}
+		}
+
+		arg, t, err := p.parseOr()
+		if err != nil {
+			return nil, 0, err
+		}
+		if t != exprString {
+			return nil, 0, fmt.Errorf("function %s needs string arguments, got %s", name.text, t)
+		}
+		args = append(args, arg)
+	}
+	if len(args) != fn.arity {
+		return nil, 0, fmt.Errorf("function %s takes %d arguments, got %d", name.text, fn.arity, len(args))
+	}
+
+	return func(v T) exprValue {
+		ss := make([]string, len(args))
+		for i, arg := range args {
+			x := arg(v)
+			if !x.valid {
+				if fn.result == exprBool {
+					return boolValue(false)
+				}
+				return exprValue{}
+			}
+			ss[i] = x.s
+		}
+		return fn.call(ss)
+	}, fn.result, nil
+}
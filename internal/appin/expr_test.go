@@ -0,0 +1,260 @@
+package appin
+
+import (
+	"slices"
+	"testing"
+)
+
+// exprItem is an item with a field of every type to evaluate expressions against.
+type exprItem struct {
+	name   string
+	amount *float64
+	open   bool
+}
+
+var exprItemFields = map[string]*exprField[*exprItem]{
+	"name": {exprString, func(i *exprItem) exprValue {
+		if i.name == "" {
+			return exprValue{}
+		}
+		return stringValue(i.name)
+	}},
+	"amount": {exprNumber, func(i *exprItem) exprValue {
+		if i.amount == nil {
+			return exprValue{}
+		}
+		return numberValue(*i.amount)
+	}},
+	"open": {exprBool, func(i *exprItem) exprValue { return boolValue(i.open) }},
+}
+
+func TestTokenizeExpr(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []string
+		wantErr bool
+	}{
+		{in: "", want: []string{}},
+		{in: "amount>=1.5", want: []string{"amount", ">=", "1.5"}},
+		{in: "a<=b<c>d", want: []string{"a", "<=", "b", "<", "c", ">", "d"}},
+		{in: "!open || a&&b", want: []string{"!", "open", "||", "a", "&&", "b"}},
+		{in: "a != b == c", want: []string{"a", "!=", "b", "==", "c"}},
+		{in: `contains(name, "x y")`, want: []string{"contains", "(", "name", ",", "x y", ")"}},
+		{in: `'it\'s' + "say \"hi\""`, want: []string{"it's", "+", `say "hi"`}},
+		{in: `"ລາວ"`, want: []string{"ລາວ"}},
+		{in: "_x1 -2*3/4", want: []string{"_x1", "-", "2", "*", "3", "/", "4"}},
+		{in: `"open`, wantErr: true},
+		{in: "a = b", wantErr: true},
+		{in: "a & b", wantErr: true},
+		{in: "a # b", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tokens, err := tokenizeExpr(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("tokenizeExpr(%q) = %d tokens, want error", tt.in, len(tokens))
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("tokenizeExpr(%q) error = %v", tt.in, err)
+			continue
+		}
+
+		if last := tokens[len(tokens)-1]; last.kind != tokEOF || last.pos != len(tt.in) {
+			t.Errorf("tokenizeExpr(%q) does not end with EOF at %d", tt.in, len(tt.in))
+		}
+
+		got := make([]string, 0, len(tokens)-1)
+		for _, tok := range tokens[:len(tokens)-1] {
+			got = append(got, tok.text)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("tokenizeExpr(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestTokenizeExprKinds(t *testing.T) {
+	tokens, err := tokenizeExpr(`amount > 10 && "x"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []tokenKind{tokIdent, tokOp, tokNumber, tokOp, tokString, tokEOF}
+	got := make([]tokenKind, 0, len(tokens))
+	for _, tok := range tokens {
+		got = append(got, tok.kind)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("kinds = %v, want %v", got, want)
+	}
+}
+
+func TestCompileExprNumber(t *testing.T) {
+	amount := 100.0
+	item := &exprItem{name: "Loan", amount: &amount}
+
+	tests := []struct {
+		in   string
+		want float64
+	}{
+		{in: "42", want: 42},
+		{in: ".5", want: 0.5},
+		{in: "1 + 2 * 3", want: 7},
+		{in: "(1 + 2) * 3", want: 9},
+		{in: "10 - 4 - 3", want: 3},
+		{in: "8 / 4 / 2", want: 1},
+		{in: "2 * 3 + 4 * 5", want: 26},
+		{in: "-2 * 3", want: -6},
+		{in: "--2", want: 2},
+		{in: "2 - -3", want: 5},
+		{in: "amount / 4 + 1", want: 26},
+		{in: "-(amount - 1)", want: -99},
+	}
+
+	for _, tt := range tests {
+		node, err := compileExpr(tt.in, exprItemFields, exprNumber)
+		if err != nil {
+			t.Errorf("compileExpr(%q) error = %v", tt.in, err)
+			continue
+		}
+		if got := node(item); !got.valid || got.n != tt.want {
+			t.Errorf("%s = %+v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCompileExprBool(t *testing.T) {
+	amount := 100.0
+	item := &exprItem{name: "Home Loan", amount: &amount, open: true}
+
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{in: "true", want: true},
+		{in: "true || false && false", want: true},
+		{in: "(true || false) && false", want: false},
+		{in: "!false && false", want: false},
+		{in: "!(false && false)", want: true},
+		{in: "!!open", want: true},
+		{in: "1 + 2 == 3 && 2 < 3", want: true},
+		{in: "amount >= 100 && amount <= 100", want: true},
+		{in: "amount > 100 || amount < 100", want: false},
+		{in: "amount != 99", want: true},
+		{in: `name == "Home Loan"`, want: true},
+		{in: `name == "home loan"`, want: false},
+		{in: `lower(name) == "home loan"`, want: true},
+		{in: `upper(trim("  a ")) == "A"`, want: true},
+		{in: `"a" < "b"`, want: true},
+		{in: `contains(name, "Loan") && startsWith(name, "Home") && endsWith(name, "Loan")`, want: true},
+		{in: `contains(lower(name), "car")`, want: false},
+		{in: "open == true", want: true},
+	}
+
+	for _, tt := range tests {
+		node, err := compileExpr(tt.in, exprItemFields, exprBool)
+		if err != nil {
+			t.Errorf("compileExpr(%q) error = %v", tt.in, err)
+			continue
+		}
+		if got := node(item); !got.valid || got.b != tt.want {
+			t.Errorf("%s = %+v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCompileExprNull(t *testing.T) {
+	item := &exprItem{}
+
+	bools := []struct {
+		in   string
+		want bool
+	}{
+		{in: "amount == null", want: true},
+		{in: "null == amount", want: true},
+		{in: "amount != null", want: false},
+		{in: "null == null", want: true},
+		{in: "name == null", want: true},
+		{in: `name == ""`, want: false},
+		{in: "amount > 0", want: false},
+		{in: "amount <= 0", want: false},
+		{in: "amount + 1 == null", want: true},
+		{in: "-amount == null", want: true},
+		{in: "1 / 0 == null", want: true},
+		{in: `contains(name, "x")`, want: false},
+		{in: `!contains(name, "x")`, want: true},
+		{in: `lower(name) == null`, want: true},
+	}
+
+	for _, tt := range bools {
+		node, err := compileExpr(tt.in, exprItemFields, exprBool)
+		if err != nil {
+			t.Errorf("compileExpr(%q) error = %v", tt.in, err)
+			continue
+		}
+		if got := node(item); !got.valid || got.b != tt.want {
+			t.Errorf("%s = %+v, want %v", tt.in, got, tt.want)
+		}
+	}
+
+	numbers := []string{"amount", "amount * 2", "1 + amount", "10 / (amount - amount)"}
+	for _, in := range numbers {
+		node, err := compileExpr(in, exprItemFields, exprNumber)
+		if err != nil {
+			t.Errorf("compileExpr(%q) error = %v", in, err)
+			continue
+		}
+		if got := node(item); got.valid {
+			t.Errorf("%s = %+v, want null", in, got)
+		}
+	}
+}
+
+func TestCompileExprErrors(t *testing.T) {
+	tests := []struct {
+		in   string
+		want exprType
+	}{
+		// Syntax
+		{in: "", want: exprBool},
+		{in: "(true", want: exprBool},
+		{in: "true)", want: exprBool},
+		{in: "1 +", want: exprNumber},
+		{in: "true false", want: exprBool},
+		{in: "1 < 2 < 3", want: exprBool},
+		{in: "1.2.3", want: exprNumber},
+		{in: `contains(name "x")`, want: exprBool},
+		{in: `contains(name, "x"`, want: exprBool},
+
+		// Names
+		{in: "missing", want: exprBool},
+		{in: "missing(name)", want: exprBool},
+
+		// Types
+		{in: "amount", want: exprBool},
+		{in: "open", want: exprNumber},
+		{in: "null", want: exprBool},
+		{in: `name + "x"`, want: exprString},
+		{in: "open + 1", want: exprNumber},
+		{in: "-open", want: exprNumber},
+		{in: "!amount", want: exprBool},
+		{in: "amount && open", want: exprBool},
+		{in: "open || 1", want: exprBool},
+		{in: `amount == "100"`, want: exprBool},
+		{in: "open < true", want: exprBool},
+		{in: "null < 1", want: exprBool},
+		{in: "amount < name", want: exprBool},
+		{in: "contains(amount, 1)", want: exprBool},
+		{in: `contains(name)`, want: exprBool},
+		{in: `lower(name, name)`, want: exprString},
+	}
+
+	for _, tt := range tests {
+		if _, err := compileExpr(tt.in, exprItemFields, tt.want); err == nil {
+			t.Errorf("compileExpr(%q, %s) succeeded, want error", tt.in, tt.want)
+		}
+	}
+}
@@ -0,0 +1,300 @@
+package appin
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	rpcstatus "google.golang.org/grpc/status"
+)
+
+const (
+	defaultForecastHorizon = 7
+	maxForecastHorizon     = 365
+
+	// forecastZ is the z-score of the 95% confidence band.
+	forecastZ = 1.96
+)
+
+// ForecastOverview is the expected backlog of every stage.
+type ForecastOverview struct {
+	// Interval is the length of each forecast step.
+	Interval Interval `json:"interval"`
+
+	// Horizon is the number of forecast steps.
+	Horizon int `json:"horizon"`
+
+	// AppIn is the forecast of App-In.
+	AppIn *StageForecast `json:"appIn"`
+
+	// CAFinal is the forecast of CA Final.
+	CAFinal *StageForecast `json:"caFinal"`
+}
+
+// StageForecast is the expected backlog of a stage, overall and by product.
+type StageForecast struct {
+	Overall   *Forecast   `json:"overall"`
+	ByProduct []*Forecast `json:"byProduct"`
+}
+
+// Forecast is the expected backlog of a group based on the arrival and completion rates of the query period.
+type Forecast struct {
+	// Name is the name of the group. Empty for the overall forecast.
+	Name string `json:"name"`
+
+	// Pending is the number of pending items now.
+	Pending int64 `json:"pending"`
+
+	// ArrivalRate is the average number of items created per interval.
+	ArrivalRate float64 `json:"arrivalRate"`
+
+	// CompletionRate is the average number of items completed per interval.
+	CompletionRate float64 `json:"completionRate"`
+
+	// TimeToClear is the expected time to clear the pending items at the current arrival and completion rates.
+	// Nil if the backlog does not shrink.
+	TimeToClear *time.Duration `json:"timeToClear"`
+
+	// TimeToClearWithoutArrivals is the expected time to clear the pending items if nothing else arrives.
+	// Nil if nothing is completed.
+	TimeToClearWithoutArrivals *time.Duration `json:"timeToClearWithoutArrivals"`
+
+	// Points is the expected backlog at the end of each step.
+	Points []*ForecastPoint `json:"points"`
+}
+
+// ForecastPoint is the expected backlog at a time with a 95% confidence band.
+type ForecastPoint struct {
+	At       time.Time `json:"at"`
+	Expected float64   `json:"expected"`
+	Lower    float64   `json:"lower"`
+	Upper    float64   `json:"upper"`
+}
+
+// forecastItem is an item counted in a forecast.
+type forecastItem struct {
+	product     string
+	createdAt   time.Time
+	completedAt *time.Time
+	pending     bool
+}
+
+func forecastItems(rs []*Record) []*forecastItem {
+	items := make([]*forecastItem, 0, len(rs))
+	for _, r := range rs {
+		items = append(items, &forecastItem{
+			product:     r.Product,
+			createdAt:   r.CreatedAt,
+			completedAt: r.CompletedAt,
+			pending:     r.pending(),
+		})
+	}
+
+	return items
+}
+
+// forecastBucket is a period of the query interval clamped to the query period.
+type forecastBucket struct {
+	start time.Time
+	end   time.Time
+
+	// length is the length of the whole period.
+	length time.Duration
+
+	// weight is the share of the whole period within the query period.
+	// The first and last periods are usually partial.
+	weight float64
+}
+
+// forecastBuckets returns the periods between after and before, clamped to [after, before).
+func forecastBuckets(after, before time.Time, interval Interval, loc *time.Location) []*forecastBucket {
+	buckets := make([]*forecastBucket, 0)
+	for _, start := range backlogPoints(after, before, interval, loc) {
+		end := interval.next(start)
+		b := &forecastBucket{
+			start:  maxTime(start, after),
+			end:    minTime(end, before),
+			length: end.Sub(start),
+		}
+		if !b.start.Before(b.end) {
+			continue
+		}
+
+		b.weight = float64(b.end.Sub(b.start)) / float64(b.length)
+		buckets = append(buckets, b)
+	}
+
+	return buckets
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+// GetForecast forecasts the backlog for the next horizon intervals from the arrival and completion
+// rates of the query period. Items created before the period and still open are pending too.
+// The rates only count the arrivals and completions within the period, and the partial first and last
+// intervals are pro-rated so they do not lower the rates.
+func (s *Service) GetForecast(ctx context.Context, q *Query) (*ForecastOverview, error) {
+	interval, err := q.interval()
+	if err != nil {
+		return nil, err
+	}
+
+	horizon := q.Horizon
+	if horizon == 0 {
+		horizon = defaultForecastHorizon
+	}
+	if horizon < 0 || horizon > maxForecastHorizon {
+		return nil, rpcstatus.Error(codes.InvalidArgument, fmt.Sprintf("horizon must be between 1 and %d.", maxForecastHorizon))
+	}
+
+	after, before := q.period()
+	if after.IsZero() {
+		return nil, rpcstatus.Error(codes.InvalidArgument, "createdAfter is required for the forecast.")
+	}
+
+	if len(backlogPoints(after, before, interval, s.loc)) > maxBacklogPoints {
+		return nil, rpcstatus.Error(codes.InvalidArgument, fmt.Sprintf("The forecast must not be based on more than %d periods. Use a longer interval.", maxBacklogPoints))
+	}
+
+	as, ca, err := s.listOpenStages(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	buckets := forecastBuckets(after, before, interval, s.loc)
+	now := time.Now()
+	return &ForecastOverview{
+		Interval: interval,
+		Horizon:  horizon,
+		AppIn:    newStageForecast(forecastItems(records(as)), buckets, interval, horizon, now),
+		CAFinal:  newStageForecast(forecastItems(records(ca)), buckets, interval, horizon, now),
+	}, nil
+}
+
+func newStageForecast(items []*forecastItem, buckets []*forecastBucket, interval Interval, horizon int, now time.Time) *StageForecast {
+	byProduct := make(map[string][]*forecastItem)
+	for _, it := range items {
+		if it.product != "" {
+			byProduct[it.product] = append(byProduct[it.product], it)
+		}
+	}
+
+	fs := make([]*Forecast, 0, len(byProduct))
+	for name, its := range byProduct {
+		fs = append(fs, newForecast(name, its, buckets, interval, horizon, now))
+	}
+	sort.Slice(fs, func(i, j int) bool {
+		if fs[i].Pending != fs[j].Pending {
+			return fs[i].Pending > fs[j].Pending
+		}
+		return fs[i].Name < fs[j].Name
+	})
+
+	return &StageForecast{
+		Overall:   newForecast("", items, buckets, interval, horizon, now),
+		ByProduct: fs,
+	}
+}
+
+// newForecast projects the pending items with the mean and variance of the net flow of each bucket.
+func newForecast(name string, items []*forecastItem, buckets []*forecastBucket, interval Interval, horizon int, now time.Time) *Forecast {
+	f := &Forecast{
+		Name:   name,
+		Points: make([]*ForecastPoint, 0, horizon),
+	}
+
+	arrivals := make([]float64, len(buckets))
+	completions := make([]float64, len(buckets))
+	// bucketOf returns the bucket containing t, or -1 if t is outside the buckets.
+	bucketOf := func(t time.Time) int {
+		if len(buckets) == 0 || t.Before(buckets[0].start) || !t.Before(buckets[len(buckets)-1].end) {
+			return -1
+		}
+		return sort.Search(len(buckets), func(i int) bool { return buckets[i].start.After(t) }) - 1
+	}
+
+	for _, it := range items {
+		if it.pending {
+			f.Pending++
+		}
+		if i := bucketOf(it.createdAt); i >= 0 {
+			arrivals[i]++
+		}
+		if !it.pending && it.completedAt != nil {
+			if i := bucketOf(*it.completedAt); i >= 0 {
+				completions[i]++
+			}
+		}
+	}
+
+	// The rates are per interval: the counts divided by the intervals observed, a partial one counting for its share.
+	var observed float64
+	for i, b := range buckets {
+		observed += b.weight
+		f.ArrivalRate += arrivals[i]
+		f.CompletionRate += completions[i]
+	}
+	if observed == 0 {
+		return f
+	}
+	f.ArrivalRate /= observed
+	f.CompletionRate /= observed
+
+	// The variance of the net flow is of the whole intervals only, since a short partial interval is noisy.
+	net := f.ArrivalRate - f.CompletionRate
+	var sumSq, whole float64
+	for i, b := range buckets {
+		if b.weight < 1 {
+			continue
+		}
+		d := arrivals[i] - completions[i] - net
+		sumSq += d * d
+		whole++
+	}
+
+	stddev := 0.0
+	if whole > 1 {
+		stddev = math.Sqrt(sumSq / (whole - 1))
+	}
+
+	length := buckets[0].length
+	if clear := f.CompletionRate - f.ArrivalRate; clear > 0 {
+		d := time.Duration(float64(f.Pending) / clear * float64(length))
+		f.TimeToClear = &d
+	}
+	if f.CompletionRate > 0 {
+		d := time.Duration(float64(f.Pending) / f.CompletionRate * float64(length))
+		f.TimeToClearWithoutArrivals = &d
+	}
+
+	at := now
+	for k := 1; k <= horizon; k++ {
+		at = interval.next(at)
+		expected := math.Max(float64(f.Pending)+float64(k)*net, 0)
+		band := forecastZ * stddev * math.Sqrt(float64(k))
+
+		f.Points = append(f.Points, &ForecastPoint{
+			At:       at,
+			Expected: expected,
+			Lower:    math.Max(expected-band, 0),
+			Upper:    expected + band,
+		})
+	}
+
+	return f
+}
@@ -0,0 +1,286 @@
+package appin
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// Funnel is the end-to-end pipeline from App-In to CA Final, joined by loan number.
+type Funnel struct {
+	// AppIns is the number of App-In.
+	AppIns int64 `json:"appIns"`
+
+	// AppInConverted is the number of converted App-In.
+	AppInConverted int64 `json:"appInConverted"`
+
+	// HandedOff is the number of converted App-In assigned to CA Final.
+	HandedOff int64 `json:"handedOff"`
+
+	// CAFinalCompleted is the number of handed off App-In whose CA Final is completed.
+	CAFinalCompleted int64 `json:"caFinalCompleted"`
+
+	// AppInConversionRate is the percentage of App-In converted. AppInConverted / AppIns
+	AppInConversionRate float32 `json:"appInConversionRate"`
+
+	// HandOffRate is the percentage of converted App-In assigned to CA Final. HandedOff / AppInConverted
+	HandOffRate float32 `json:"handOffRate"`
+
+	// CAFinalCompletionRate is the percentage of handed off App-In completed in CA Final. CAFinalCompleted / HandedOff
+	CAFinalCompletionRate float32 `json:"caFinalCompletionRate"`
+
+	// EndToEndRate is the percentage of App-In completed in CA Final. CAFinalCompleted / AppIns
+	EndToEndRate float32 `json:"endToEndRate"`
+
+	// HandOffGap is the time between App-In completion and CA Final assignment.
+	HandOffGap *FunnelTiming `json:"handOffGap"`
+
+	// LeadTime is the time between App-In creation and CA Final completion.
+	LeadTime *FunnelTiming `json:"leadTime"`
+
+	// Stuck is the converted App-In not assigned to CA Final yet, the longest waiting first.
+	Stuck []*StuckCase `json:"stuck"`
+
+	// UnmatchedCAFinals is the number of CA Final of the product assigned in the period without any App-In.
+	// CA Final of App-In created before the period are not counted.
+	UnmatchedCAFinals int64 `json:"unmatchedCaFinals"`
+
+	// InvalidHandOffs is the handed off App-In whose CA Final was assigned before the App-In was completed.
+	// Their hand-off gap is left out of HandOffGap since the dates are wrong.
+	InvalidHandOffs []*InvalidHandOff `json:"invalidHandOffs"`
+}
+
+// InvalidHandOff is a CA Final assigned before its App-In was completed.
+type InvalidHandOff struct {
+	Number           string        `json:"number"`
+	DisplayName      string        `json:"displayName"`
+	AppInCompletedAt time.Time     `json:"appInCompletedAt"`
+	CAFinalAssignAt  time.Time     `json:"caFinalAssignAt"`
+	Gap              time.Duration `json:"gap"`
+}
+
+// FunnelTiming is the distribution of a time between stages.
+type FunnelTiming struct {
+	Count   int64         `json:"count"`
+	Average time.Duration `json:"average"`
+	P50     time.Duration `json:"p50"`
+	P90     time.Duration `json:"p90"`
+}
+
+// StuckCase is a converted App-In waiting to be assigned to CA Final.
+type StuckCase struct {
+	Number           string        `json:"number"`
+	DisplayName      string        `json:"displayName"`
+	Product          string        `json:"product"`
+	Executor         string        `json:"executor"`
+	AppInCompletedAt time.Time     `json:"appInCompletedAt"`
+	Waiting          time.Duration `json:"waiting"`
+}
+
+// GetFunnel returns the pipeline of App-In matching the query through CA Final.
+// CA Final of the product assigned in the period are joined, then the CA Final of the converted App-In handed off
+// after the period and the App-In of the CA Final created before it are looked up by loan number.
+func (s *Service) GetFunnel(ctx context.Context, q *Query) (*Funnel, error) {
+	var (
+		as []*AppIn
+		ca []*CAFinal
+	)
+
+	after, before := q.period()
+	g, gctx := errgroup.WithContext(ctx)
+
+	g.Go(func() (err error) {
+		as, err = s.listAppIns(gctx, q)
+		return
+	})
+
+	g.Go(func() (err error) {
+		ca, err = s.listCAFinals(gctx, &Query{CreatedAfter: after, CreatedBefore: before})
+		return
+	})
+
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	// The product of the CA Final is checked before looking up the other stage, so the other products are not looked up.
+	// CA Final whose product comes from App-In only know it from the App-In of the period.
+	if s.caColumns.joined() {
+		joinAppIns(ca, as)
+	}
+	ca = caFinalsOfProduct(ca, q.Product)
+
+	appIns := make(map[string]bool, len(as))
+	for _, a := range as {
+		appIns[loanKey(a.Number)] = true
+	}
+	caFinals := indexCAFinalByLoan(ca)
+
+	var (
+		later   []*CAFinal
+		earlier []*AppIn
+	)
+
+	g, gctx = errgroup.WithContext(ctx)
+
+	g.Go(func() (err error) {
+		numbers := make([]string, 0)
+		for _, a := range as {
+			key := loanKey(a.Number)
+			if _, ok := caFinals[key]; a.converted() && key != "" && !ok {
+				numbers = append(numbers, strings.TrimSpace(a.Number))
+			}
+		}
+
+		later, err = s.listCAFinalsByLoans(gctx, numbers)
+		return
+	})
+
+	g.Go(func() (err error) {
+		numbers := make([]string, 0)
+		for key, c := range caFinals {
+			if !appIns[key] {
+				numbers = append(numbers, strings.TrimSpace(c.Number))
+			}
+		}
+
+		earlier, err = s.listAppInsByLoans(gctx, numbers)
+		return
+	})
+
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	return newFunnel(as, ca, later, earlier), nil
+}
+
+// loanKey normalizes a loan number to join App-In with CA Final.
+func loanKey(number string) string {
+	return strings.ToUpper(strings.TrimSpace(number))
+}
+
+// indexCAFinalByLoan returns the earliest assigned CA Final of each loan number.
+func indexCAFinalByLoan(ca []*CAFinal) map[string]*CAFinal {
+	index := make(map[string]*CAFinal, len(ca))
+	for _, c := range ca {
+		key := loanKey(c.Number)
+		if key == "" {
+			continue
+		}
+		if prev, ok := index[key]; !ok || c.CreatedAt.Before(prev.CreatedAt) {
+			index[key] = c
+		}
+	}
+
+	return index
+}
+
+// newFunnel joins the App-In of the period with the CA Final of the period and the CA Final handed off later.
+// The App-In created earlier are only used to tell the CA Final of the period that have no App-In at all.
+func newFunnel(as []*AppIn, ca, later []*CAFinal, earlier []*AppIn) *Funnel {
+	f := &Funnel{
+		AppIns:          int64(len(as)),
+		Stuck:           make([]*StuckCase, 0),
+		InvalidHandOffs: make([]*InvalidHandOff, 0),
+	}
+
+	known := make(map[string]bool, len(as)+len(earlier))
+	for _, a := range slices.Concat(as, earlier) {
+		known[loanKey(a.Number)] = true
+	}
+	for key := range indexCAFinalByLoan(ca) {
+		if !known[key] {
+			f.UnmatchedCAFinals++
+		}
+	}
+
+	index := indexCAFinalByLoan(slices.Concat(ca, later))
+	gaps := make([]time.Duration, 0)
+	leads := make([]time.Duration, 0)
+
+	now := time.Now()
+	for _, a := range as {
+		key := loanKey(a.Number)
+		if !a.converted() {
+			continue
+		}
+		f.AppInConverted++
+
+		c, ok := index[key]
+		if key == "" || !ok {
+			f.Stuck = append(f.Stuck, &StuckCase{
+				Number:           a.Number,
+				DisplayName:      a.DisplayName,
+				Product:          a.Product,
+				Executor:         a.Executor,
+				AppInCompletedAt: *a.CompletedAt,
+				Waiting:          now.Sub(*a.CompletedAt),
+			})
+			continue
+		}
+
+		f.HandedOff++
+		if gap := c.CreatedAt.Sub(*a.CompletedAt); gap >= 0 {
+			gaps = append(gaps, gap)
+		} else {
+			f.InvalidHandOffs = append(f.InvalidHandOffs, &InvalidHandOff{
+				Number:           a.Number,
+				DisplayName:      a.DisplayName,
+				AppInCompletedAt: *a.CompletedAt,
+				CAFinalAssignAt:  c.CreatedAt,
+				Gap:              gap,
+			})
+		}
+
+		if c.converted() {
+			f.CAFinalCompleted++
+			leads = append(leads, c.CompletedAt.Sub(a.CreatedAt))
+		}
+	}
+
+	f.AppInConversionRate = rate(f.AppInConverted, f.AppIns)
+	f.HandOffRate = rate(f.HandedOff, f.AppInConverted)
+	f.CAFinalCompletionRate = rate(f.CAFinalCompleted, f.HandedOff)
+	f.EndToEndRate = rate(f.CAFinalCompleted, f.AppIns)
+	f.HandOffGap = newFunnelTiming(gaps)
+	f.LeadTime = newFunnelTiming(leads)
+
+	sort.Slice(f.Stuck, func(i, j int) bool {
+		return f.Stuck[i].Waiting > f.Stuck[j].Waiting
+	})
+
+	return f
+}
+
+func newFunnelTiming(ds []time.Duration) *FunnelTiming {
+	t := &FunnelTiming{Count: int64(len(ds))}
+	if len(ds) == 0 {
+		return t
+	}
+
+	var sum time.Duration
+	for _, d := range ds {
+		sum += d
+	}
+
+	sorted := sortDurations(ds)
+	t.Average = sum / time.Duration(len(ds))
+	t.P50 = percentile(sorted, 50)
+	t.P90 = percentile(sorted, 90)
+
+	return t
+}
+
+// rate returns n / total as a percentage, or zero if total is zero.
+func rate(n, total int64) float32 {
+	if total == 0 {
+		return 0
+	}
+
+	return float32(n) / float32(total) * 100
+}
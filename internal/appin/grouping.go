@@ -0,0 +1,176 @@
+package appin
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	rpcstatus "google.golang.org/grpc/status"
+)
+
+// DefaultGroupingScheme is the name of the grouping scheme used when none is requested.
+const DefaultGroupingScheme = "default"
+
+// GroupingSchemes is the product grouping schemes by name.
+type GroupingSchemes map[string]*GroupingScheme
+
+// GroupingScheme groups App-In into products for ProductMetrics.
+// Rules are matched in order, the first match wins.
+// App-In no rule matches are grouped by their product name.
+type GroupingScheme struct {
+	Rules []*GroupingRule `json:"rules"`
+}
+
+// GroupingRule labels the App-In matching every condition of the rule.
+// A nil condition matches any value.
+type GroupingRule struct {
+	Product      *GroupingMatch `json:"product"`
+	CustomerType *GroupingMatch `json:"customerType"`
+
+	// Source is the stage the item comes from. ex: "appin"
+	Source *GroupingMatch `json:"source"`
+
+	// Label is the product label of the matching App-In.
+	// "{product}", "{customerType}" and "{source}" are replaced with the values of the App-In.
+	Label string `json:"label"`
+
+	// Exclude drops the matching App-In from the product metrics.
+	Exclude bool `json:"exclude"`
+}
+
+// GroupingMatch is a condition on a value. Values are compared case-insensitively.
+type GroupingMatch struct {
+	// Equals matches the value equal to it.
+	Equals string `json:"equals"`
+
+	// Contains matches the value containing it.
+	Contains string `json:"contains"`
+
+	// Empty matches the empty value.
+	Empty bool `json:"empty"`
+}
+
+func (m *GroupingMatch) matches(v string) bool {
+	if m == nil {
+		return true
+	}
+
+	v = strings.ToLower(strings.TrimSpace(v))
+	switch {
+	case m.Empty:
+		return v == ""
+	case m.Equals != "":
+		return v == strings.ToLower(m.Equals)
+	case m.Contains != "":
+		return strings.Contains(v, strings.ToLower(m.Contains))
+	default:
+		return true
+	}
+}
+
+// DefaultGroupingSchemes returns the schemes used when none is configured.
+// The default scheme splits "Sale Auto" into C4C, Used Car and MC by customer type
+// and drops App-In without a customer type.
+func DefaultGroupingSchemes() GroupingSchemes {
+	saleAuto := &GroupingMatch{Equals: "sale auto"}
+	return GroupingSchemes{
+		DefaultGroupingScheme: {
+			Rules: []*GroupingRule{
+				{CustomerType: &GroupingMatch{Empty: true}, Exclude: true},
+				{Product: saleAuto, CustomerType: &GroupingMatch{Contains: "c4c"}, Label: "C4C | {product}"},
+				{Product: saleAuto, CustomerType: &GroupingMatch{Contains: "used car"}, Label: "Used Car | {product}"},
+				{Product: saleAuto, CustomerType: &GroupingMatch{Contains: "mc"}, Label: "MC | {product}"},
+			},
+		},
+		"product": {
+			Rules: make([]*GroupingRule, 0),
+		},
+		"customerType": {
+			Rules: []*GroupingRule{
+				{CustomerType: &GroupingMatch{Empty: true}, Exclude: true},
+				{Label: "{customerType}"},
+			},
+		},
+	}
+}
+
+// ReadGroupingSchemesFile reads grouping schemes from a JSON file.
+// Default schemes missing from the file are added.
+func ReadGroupingSchemesFile(name string) (GroupingSchemes, error) {
+	byt, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read grouping schemes: %w", err)
+	}
+
+	gs := make(GroupingSchemes)
+	if err := json.Unmarshal(byt, &gs); err != nil {
+		return nil, fmt.Errorf("failed to parse grouping schemes: %w", err)
+	}
+
+	for name, g := range DefaultGroupingSchemes() {
+		if _, ok := gs[name]; !ok {
+			gs[name] = g
+		}
+	}
+
+	return gs, gs.Validate()
+}
+
+func (gs GroupingSchemes) Validate() error {
+	if _, ok := gs[DefaultGroupingScheme]; !ok {
+		return fmt.Errorf("grouping scheme %q is missing", DefaultGroupingScheme)
+	}
+
+	for name, g := range gs {
+		if g == nil {
+			return fmt.Errorf("grouping scheme %q is nil", name)
+		}
+		for i, r := range g.Rules {
+			if r == nil {
+				return fmt.Errorf("grouping rule %d of %q is nil", i, name)
+			}
+			if !r.Exclude && r.Label == "" {
+				return fmt.Errorf("grouping rule %d of %q has no label", i, name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// scheme returns the grouping scheme of the query.
+func (gs GroupingSchemes) scheme(q *Query) (*GroupingScheme, error) {
+	name := q.Grouping
+	if name == "" {
+		name = DefaultGroupingScheme
+	}
+
+	g, ok := gs[name]
+	if !ok {
+		return nil, rpcstatus.Error(codes.InvalidArgument, fmt.Sprintf("Grouping scheme %q does not exist.", name))
+	}
+
+	return g, nil
+}
+
+// label returns the product label of an item of the stage and whether it is kept.
+func (g *GroupingScheme) label(product, customerType string, source Stage) (string, bool) {
+	for _, r := range g.Rules {
+		if !r.Product.matches(product) || !r.CustomerType.matches(customerType) || !r.Source.matches(string(source)) {
+			continue
+		}
+		if r.Exclude {
+			return "", false
+		}
+
+		return strings.NewReplacer(
+			"{product}", product,
+			"{customerType}", customerType,
+			"{source}", string(source),
+		).Replace(r.Label), true
+	}
+
+	return product, true
+}
@@ -0,0 +1,95 @@
+package appin
+
+import (
+	"context"
+	"time"
+)
+
+// HeatmapOverview is when items arrive and get completed in every stage.
+type HeatmapOverview struct {
+	// Timezone is the timezone of the days and hours.
+	Timezone string `json:"timezone"`
+
+	// Days is the label of each row. The week starts on Monday.
+	Days []string `json:"days"`
+
+	// AppIn is the heatmap of App-In.
+	AppIn *Heatmap `json:"appIn"`
+
+	// CAFinal is the heatmap of CA Final.
+	CAFinal *Heatmap `json:"caFinal"`
+}
+
+// Heatmap is a day of week by hour of day matrix. Rows are days starting on Monday, columns are hours.
+type Heatmap struct {
+	// Arrivals is the number of items created in each hour.
+	Arrivals [7][24]int64 `json:"arrivals"`
+
+	// Completions is the number of items completed in each hour.
+	Completions [7][24]int64 `json:"completions"`
+
+	// MedianTurnaround is the median time used by the items completed in each hour.
+	MedianTurnaround [7][24]time.Duration `json:"medianTurnaround"`
+}
+
+// GetHeatmap returns when the items matching the query arrive and get completed.
+func (s *Service) GetHeatmap(ctx context.Context, q *Query) (*HeatmapOverview, error) {
+	as, ca, err := s.listStages(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	appIns := make([]*heatmapItem, 0, len(as))
+	for _, a := range as {
+		appIns = append(appIns, &heatmapItem{createdAt: a.CreatedAt, completedAt: a.CompletedAt})
+	}
+
+	caFinals := make([]*heatmapItem, 0, len(ca))
+	for _, c := range ca {
+		caFinals = append(caFinals, &heatmapItem{createdAt: c.CreatedAt, completedAt: c.CompletedAt})
+	}
+
+	return &HeatmapOverview{
+		Timezone: s.loc.String(),
+		Days:     []string{"Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"},
+		AppIn:    newHeatmap(appIns, s.loc),
+		CAFinal:  newHeatmap(caFinals, s.loc),
+	}, nil
+}
+
+type heatmapItem struct {
+	createdAt   time.Time
+	completedAt *time.Time
+}
+
+// heatmapCell returns the row and column of t in loc.
+func heatmapCell(t time.Time, loc *time.Location) (int, int) {
+	t = t.In(loc)
+	return (int(t.Weekday()) + 6) % 7, t.Hour()
+}
+
+func newHeatmap(items []*heatmapItem, loc *time.Location) *Heatmap {
+	h := new(Heatmap)
+	var turnarounds [7][24][]time.Duration
+
+	for _, it := range items {
+		d, hr := heatmapCell(it.createdAt, loc)
+		h.Arrivals[d][hr]++
+
+		if it.completedAt == nil {
+			continue
+		}
+
+		d, hr = heatmapCell(*it.completedAt, loc)
+		h.Completions[d][hr]++
+		turnarounds[d][hr] = append(turnarounds[d][hr], it.completedAt.Sub(it.createdAt))
+	}
+
+	for d := range turnarounds {
+		for hr := range turnarounds[d] {
+			h.MedianTurnaround[d][hr] = percentile(sortDurations(turnarounds[d][hr]), 50)
+		}
+	}
+
+	return h
+}
@@ -0,0 +1,79 @@
+package appin
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	rpcstatus "google.golang.org/grpc/status"
+)
+
+// Interval is the length of the periods of a time series.
+type Interval string
+
+const (
+	IntervalHour  Interval = "hour"
+	IntervalDay   Interval = "day"
+	IntervalWeek  Interval = "week"
+	IntervalMonth Interval = "month"
+)
+
+// interval returns the interval of the query. Defaults to IntervalDay.
+func (q *Query) interval() (Interval, error) {
+	switch v := Interval(strings.ToLower(q.Interval)); v {
+	case "":
+		return IntervalDay, nil
+	case IntervalHour, IntervalDay, IntervalWeek, IntervalMonth:
+		return v, nil
+	default:
+		return "", rpcstatus.Error(codes.InvalidArgument, "interval must be one of: hour, day, week, month.")
+	}
+}
+
+// truncate returns the start of the period containing t in loc.
+func (i Interval) truncate(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	switch i {
+	case IntervalHour:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+	case IntervalWeek:
+		// Weeks start on Monday.
+		offset := (int(t.Weekday()) + 6) % 7
+		return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, t.Location())
+	case IntervalMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	}
+}
+
+// next returns the start of the period following the one starting at t.
+func (i Interval) next(t time.Time) time.Time {
+	switch i {
+	case IntervalHour:
+		return t.Add(time.Hour)
+	case IntervalWeek:
+		return t.AddDate(0, 0, 7)
+	case IntervalMonth:
+		return t.AddDate(0, 1, 0)
+	default:
+		return t.AddDate(0, 0, 1)
+	}
+}
+
+// key returns the label of the period containing t in loc. ex: "2025-01-31", "2025-W05"
+func (i Interval) key(t time.Time, loc *time.Location) string {
+	t = i.truncate(t, loc)
+	switch i {
+	case IntervalHour:
+		return t.Format("2006-01-02T15")
+	case IntervalWeek:
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	case IntervalMonth:
+		return t.Format("2006-01")
+	default:
+		return t.Format(time.DateOnly)
+	}
+}
@@ -0,0 +1,273 @@
+package appin
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	rpcstatus "google.golang.org/grpc/status"
+)
+
+// LeaderboardSort is the key the leaderboard is ranked by.
+type LeaderboardSort string
+
+const (
+	// SortByConverted ranks by converted count, then conversion rate, then average time.
+	SortByConverted LeaderboardSort = "converted"
+
+	// SortByRate ranks by conversion rate, then converted count, then average time.
+	SortByRate LeaderboardSort = "rate"
+
+	// SortByAverage ranks by the shortest average time, then converted count.
+	SortByAverage LeaderboardSort = "average"
+
+	// SortByP90 ranks by the shortest 90th percentile time, then converted count.
+	SortByP90 LeaderboardSort = "p90"
+
+	// SortByVolume ranks by total count, then converted count, then conversion rate.
+	SortByVolume LeaderboardSort = "volume"
+
+	// SortByWilson ranks by the lower bound of the Wilson score interval of the conversion, then converted count.
+	SortByWilson LeaderboardSort = "wilson"
+
+	// SortByBayesian ranks by the Bayesian-smoothed conversion, then converted count.
+	SortByBayesian LeaderboardSort = "bayesian"
+
+	// SortByScore ranks by the composite performance score, then converted count.
+	SortByScore LeaderboardSort = "score"
+)
+
+// Ranking is how tied performers are ranked.
+type Ranking string
+
+const (
+	// RankingCompetition gives tied performers the same rank and skips the following ranks. ex: 1, 2, 2, 4
+	RankingCompetition Ranking = "competition"
+
+	// RankingDense gives tied performers the same rank without gaps. ex: 1, 2, 2, 3
+	RankingDense Ranking = "dense"
+)
+
+// LeaderboardOptions is how the leaderboards are ranked and paged.
+type LeaderboardOptions struct {
+	// Limit is the maximum number of entries. Defaults to 5.
+	Limit int
+
+	// Offset is the number of entries to skip.
+	Offset int
+
+	// Sort is the ranking key. Defaults to SortByConverted.
+	Sort LeaderboardSort
+
+	// MinVolume is the minimum total count for a performer to be ranked.
+	MinVolume int64
+
+	// Ranking is how ties are ranked. Defaults to RankingCompetition.
+	Ranking Ranking
+
+	// Weights is the weights of the composite performance score. Defaults to DefaultScoreWeights.
+	Weights *ScoreWeights
+}
+
+const maxLeaderboardLimit = 500
+
+// DefaultLeaderboardOptions returns the top 5 performers by converted count.
+func DefaultLeaderboardOptions() *LeaderboardOptions {
+	return &LeaderboardOptions{
+		Limit:   5,
+		Sort:    SortByConverted,
+		Ranking: RankingCompetition,
+		Weights: DefaultScoreWeights(),
+	}
+}
+
+// leaderboardOptions returns the leaderboard options of the query.
+func (q *Query) leaderboardOptions() (*LeaderboardOptions, error) {
+	o := DefaultLeaderboardOptions()
+
+	if q.LeaderboardLimit != 0 {
+		if q.LeaderboardLimit < 0 || q.LeaderboardLimit > maxLeaderboardLimit {
+			return nil, rpcstatus.Error(codes.InvalidArgument, fmt.Sprintf("leaderboardLimit must be between 1 and %d.", maxLeaderboardLimit))
+		}
+		o.Limit = q.LeaderboardLimit
+	}
+
+	if q.LeaderboardOffset < 0 {
+		return nil, rpcstatus.Error(codes.InvalidArgument, "leaderboardOffset must not be negative.")
+	}
+	o.Offset = q.LeaderboardOffset
+
+	if q.LeaderboardMinVolume < 0 {
+		return nil, rpcstatus.Error(codes.InvalidArgument, "leaderboardMinVolume must not be negative.")
+	}
+	o.MinVolume = q.LeaderboardMinVolume
+
+	if q.LeaderboardSort != "" {
+		switch v := LeaderboardSort(strings.ToLower(q.LeaderboardSort)); v {
+		case SortByConverted, SortByRate, SortByAverage, SortByP90, SortByVolume, SortByWilson, SortByBayesian, SortByScore:
+			o.Sort = v
+		default:
+			return nil, rpcstatus.Error(codes.InvalidArgument, "leaderboardSort must be one of: converted, rate, average, p90, volume, wilson, bayesian, score.")
+		}
+	}
+
+	if q.LeaderboardRanking != "" {
+		switch v := Ranking(strings.ToLower(q.LeaderboardRanking)); v {
+		case RankingCompetition, RankingDense:
+			o.Ranking = v
+		default:
+			return nil, rpcstatus.Error(codes.InvalidArgument, "leaderboardRanking must be one of: competition, dense.")
+		}
+	}
+
+	return o, nil
+}
+
+// rankedPerformer is a performer with its rank.
+type rankedPerformer struct {
+	*performerMetric
+	Rank int64
+}
+
+// createLeaderboards creates the page of the leaderboard described by the options.
+// It returns the entries and the number of ranked performers.
+func createLeaderboards(conversions map[string]*performerMetric, o *LeaderboardOptions) ([]*Leaderboard, int64) {
+	performers := rankPerformers(conversions, o)
+
+	leaderboards := make([]*Leaderboard, 0, o.Limit)
+	for i := o.Offset; i < len(performers) && len(leaderboards) < o.Limit; i++ {
+		leaderboards = append(leaderboards, newLeaderboard(performers[i].Rank, performers[i].performerMetric))
+	}
+
+	return leaderboards, int64(len(performers))
+}
+
+func newLeaderboard(rank int64, p *performerMetric) *Leaderboard {
+	return &Leaderboard{
+		Rank:           rank,
+		DisplayName:    p.DisplayName,
+		Converted:      p.Conversion.Converted,
+		Total:          p.Conversion.Total,
+		ConversionRate: p.Conversion.Rate,
+		Performances:   p.Performances,
+		AverageTime:    p.Conversion.AverageTime,
+		BestTime:       p.Conversion.BestTime,
+		P90Time:        p.P90,
+
+		ConversionInterval: p.Interval,
+		AdjustedRate:       p.AdjustedRate,
+		Score:              p.Score,
+	}
+}
+
+// rankPerformers returns the performers eligible under the options, sorted and ranked.
+func rankPerformers(conversions map[string]*performerMetric, o *LeaderboardOptions) []*rankedPerformer {
+	performers := make([]*performerMetric, 0, len(conversions))
+	for _, c := range conversions {
+		if c.Conversion.Total < o.MinVolume {
+			continue
+		}
+		performers = append(performers, c)
+	}
+	setConfidence(performers)
+	setScores(performers, o.Weights)
+
+	compare := performerComparator(o.Sort)
+	sort.Slice(performers, func(i, j int) bool {
+		if c := compare(performers[i], performers[j]); c != 0 {
+			return c < 0
+		}
+
+		// Keep the order stable for performers tied on every key.
+		return performers[i].DisplayName < performers[j].DisplayName
+	})
+
+	ranked := make([]*rankedPerformer, 0, len(performers))
+	for i, p := range performers {
+		rank := int64(i + 1)
+		if i > 0 && compare(performers[i-1], p) == 0 {
+			rank = ranked[i-1].Rank
+		} else if i > 0 && o.Ranking == RankingDense {
+			rank = ranked[i-1].Rank + 1
+		}
+
+		ranked = append(ranked, &rankedPerformer{
+			performerMetric: p,
+			Rank:            rank,
+		})
+	}
+
+	return ranked
+}
+
+// performerComparator returns a function reporting whether a ranks before (-1), after (1) or tied (0) with b.
+func performerComparator(key LeaderboardSort) func(a, b *performerMetric) int {
+	converted := func(a, b *performerMetric) int { return descending(a.Conversion.Converted, b.Conversion.Converted) }
+	rate := func(a, b *performerMetric) int { return descending(a.Conversion.Rate, b.Conversion.Rate) }
+	volume := func(a, b *performerMetric) int { return descending(a.Conversion.Total, b.Conversion.Total) }
+
+	// A zero time means nothing was converted, so it ranks last.
+	average := func(a, b *performerMetric) int {
+		return ascendingNonZero(int64(a.Conversion.AverageTime), int64(b.Conversion.AverageTime))
+	}
+	p90 := func(a, b *performerMetric) int { return ascendingNonZero(int64(a.P90), int64(b.P90)) }
+	wilson := func(a, b *performerMetric) int { return descending(a.Interval.Lower, b.Interval.Lower) }
+	bayesian := func(a, b *performerMetric) int { return descending(a.AdjustedRate, b.AdjustedRate) }
+	score := func(a, b *performerMetric) int { return descending(a.Score.Total, b.Score.Total) }
+
+	var keys []func(a, b *performerMetric) int
+	switch key {
+	case SortByRate:
+		keys = append(keys, rate, converted, average)
+	case SortByAverage:
+		keys = append(keys, average, converted)
+	case SortByP90:
+		keys = append(keys, p90, converted)
+	case SortByVolume:
+		keys = append(keys, volume, converted, rate)
+	case SortByWilson:
+		keys = append(keys, wilson, converted)
+	case SortByBayesian:
+		keys = append(keys, bayesian, converted)
+	case SortByScore:
+		keys = append(keys, score, converted)
+	default:
+		keys = append(keys, converted, rate, average)
+	}
+
+	return func(a, b *performerMetric) int {
+		for _, k := range keys {
+			if c := k(a, b); c != 0 {
+				return c
+			}
+		}
+		return 0
+	}
+}
+
+func descending[T int64 | float32 | float64](a, b T) int {
+	switch {
+	case a > b:
+		return -1
+	case a < b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func ascendingNonZero(a, b int64) int {
+	switch {
+	case a == b:
+		return 0
+	case a == 0:
+		return 1
+	case b == 0:
+		return -1
+	case a < b:
+		return -1
+	default:
+		return 1
+	}
+}
@@ -0,0 +1,233 @@
+package appin
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	rpcstatus "google.golang.org/grpc/status"
+)
+
+// maxListLimit is the maximum number of items of a page.
+const maxListLimit = 1000
+
+// ListSort is the field items of a list are sorted by.
+type ListSort string
+
+const (
+	ListSortCreatedAt   ListSort = "createdAt"
+	ListSortCompletedAt ListSort = "completedAt"
+	ListSortAge         ListSort = "age"
+	ListSortNumber      ListSort = "number"
+	ListSortExecutor    ListSort = "executor"
+	ListSortStatus      ListSort = "status"
+)
+
+// ListOptions is how the App-In and CA Final lists are filtered, searched, sorted and paged.
+type ListOptions struct {
+	// Executor, Status and Outcome keep the items equal to them case-insensitively. Any if empty.
+	Executor string
+	Status   string
+	Outcome  Outcome
+
+	// Search keeps the items whose number, display name, executor, status, product or customer type contains it case-insensitively.
+	Search string
+
+	Sort ListSort
+
+	// Descending sorts the largest first.
+	Descending bool
+
+	// Limit is the maximum number of items of the page.
+	// Every item is listed if the limit is not set, so the lists can still be exported whole.
+	Limit  int
+	Offset int
+}
+
+// listOptions returns the list options of the query.
+// Items are sorted by createdAt descending unless sorted otherwise,
+// and the items of a drill-down bucket by age descending.
+func (q *Query) listOptions() (*ListOptions, error) {
+	o := &ListOptions{
+		Executor:   strings.TrimSpace(q.Executor),
+		Status:     strings.TrimSpace(q.Status),
+		Search:     strings.ToLower(strings.TrimSpace(q.Search)),
+		Sort:       ListSortCreatedAt,
+		Descending: true,
+	}
+	if q.ConvertedBucket != "" || q.PendingBucket != "" {
+		o.Sort = ListSortAge
+	}
+
+	if q.Outcome != "" {
+		switch v := Outcome(strings.ToLower(q.Outcome)); v {
+		case OutcomeConverted, OutcomeRejected, OutcomeCancelled, OutcomePending, OutcomeReturned:
+			o.Outcome = v
+		default:
+			return nil, rpcstatus.Error(codes.InvalidArgument, "outcome must be one of: converted, rejected, cancelled, pending, returned.")
+		}
+	}
+
+	if q.Sort != "" {
+		sort := strings.TrimPrefix(q.Sort, "-")
+		switch v := ListSort(sort); v {
+		case ListSortCreatedAt, ListSortCompletedAt, ListSortAge, ListSortNumber, ListSortExecutor, ListSortStatus:
+			o.Sort = v
+			o.Descending = strings.HasPrefix(q.Sort, "-")
+		default:
+			return nil, rpcstatus.Error(codes.InvalidArgument, "sort must be one of: createdAt, completedAt, age, number, executor, status. Prefix with - to sort descending.")
+		}
+	}
+
+	if q.Limit != 0 {
+		if q.Limit < 0 || q.Limit > maxListLimit {
+			return nil, rpcstatus.Error(codes.InvalidArgument, fmt.Sprintf("limit must be between 1 and %d.", maxListLimit))
+		}
+		o.Limit = q.Limit
+	}
+
+	if q.Offset < 0 {
+		return nil, rpcstatus.Error(codes.InvalidArgument, "offset must not be negative.")
+	}
+	o.Offset = q.Offset
+
+	return o, nil
+}
+
+// listItem is the fields of an App-In or CA Final the lists filter and sort on.
+type listItem struct {
+	number       string
+	displayName  string
+	executor     string
+	status       string
+	product      string
+	customerType string
+	outcome      Outcome
+	createdAt    time.Time
+	completedAt  *time.Time
+	age          time.Duration
+}
+
+func (a *AppIn) listItem() *listItem {
+	return &listItem{
+		number:       a.Number,
+		displayName:  a.DisplayName,
+		executor:     a.Executor,
+		status:       a.Status,
+		product:      a.Product,
+		customerType: a.Type,
+		outcome:      a.Outcome,
+		createdAt:    a.CreatedAt,
+		completedAt:  a.CompletedAt,
+		age:          a.Age,
+	}
+}
+
+func (c *CAFinal) listItem() *listItem {
+	return &listItem{
+		number:       c.Number,
+		displayName:  c.DisplayName,
+		executor:     c.Executor,
+		status:       c.Status,
+		product:      c.Product,
+		customerType: c.Type,
+		outcome:      c.Outcome,
+		createdAt:    c.CreatedAt,
+		completedAt:  c.CompletedAt,
+		age:          c.Age,
+	}
+}
+
+func (i *listItem) matches(o *ListOptions) bool {
+	if o.Executor != "" && !strings.EqualFold(i.executor, o.Executor) {
+		return false
+	}
+	if o.Status != "" && !strings.EqualFold(i.status, o.Status) {
+		return false
+	}
+	if o.Outcome != "" && i.outcome != o.Outcome {
+		return false
+	}
+	if o.Search == "" {
+		return true
+	}
+
+	for _, f := range []string{i.number, i.displayName, i.executor, i.status, i.product, i.customerType} {
+		if strings.Contains(strings.ToLower(f), o.Search) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// compareListItems compares the items by the sort field, ascending unless descending.
+// Items without a completed time sort last either way.
+func compareListItems(a, b *listItem, sort ListSort, descending bool) int {
+	c := 0
+	switch sort {
+	case ListSortCompletedAt:
+		switch {
+		case a.completedAt == nil && b.completedAt == nil:
+			return 0
+		case a.completedAt == nil:
+			return 1
+		case b.completedAt == nil:
+			return -1
+		}
+		c = a.completedAt.Compare(*b.completedAt)
+	case ListSortAge:
+		c = cmp.Compare(a.age, b.age)
+	case ListSortNumber:
+		c = cmp.Compare(a.number, b.number)
+	case ListSortExecutor:
+		c = cmp.Compare(strings.ToLower(a.executor), strings.ToLower(b.executor))
+	case ListSortStatus:
+		c = cmp.Compare(strings.ToLower(a.status), strings.ToLower(b.status))
+	default:
+		c = a.createdAt.Compare(b.createdAt)
+	}
+
+	if descending {
+		return -c
+	}
+	return c
+}
+
+// listPage filters, searches and sorts the items and returns the page with the number of matching items.
+func listPage[T interface{ listItem() *listItem }](items []T, o *ListOptions) ([]T, int64) {
+	matched := make([]T, 0, len(items))
+	views := make(map[int]*listItem, len(items))
+	for _, item := range items {
+		v := item.listItem()
+		if v.matches(o) {
+			views[len(matched)] = v
+			matched = append(matched, item)
+		}
+	}
+
+	indexes := make([]int, len(matched))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	slices.SortStableFunc(indexes, func(i, j int) int {
+		return compareListItems(views[i], views[j], o.Sort, o.Descending)
+	})
+
+	total := int64(len(matched))
+	start := min(o.Offset, len(indexes))
+	end := len(indexes)
+	if o.Limit > 0 {
+		end = min(start+o.Limit, end)
+	}
+
+	page := make([]T, 0, end-start)
+	for _, i := range indexes[start:end] {
+		page = append(page, matched[i])
+	}
+
+	return page, total
+}
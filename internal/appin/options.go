@@ -0,0 +1,87 @@
+package appin
+
+import "time"
+
+// OverviewOptions is what the metrics of a request are calculated with.
+type OverviewOptions struct {
+	SLA         *SLAPolicy
+	Leaderboard *LeaderboardOptions
+	Submitter   *SubmitterOptions
+	Grouping    *GroupingScheme
+	Location    *time.Location
+	Interval    Interval
+
+	// OutlierMethod is the method used to detect outliers.
+	OutlierMethod OutlierMethod
+
+	// ExcludeOutliers excludes the outliers from the metrics.
+	ExcludeOutliers bool
+
+	// CustomMetrics is the user-defined metrics of the overview.
+	CustomMetrics CustomMetrics
+}
+
+// overviewOptions returns the options of the query with the configuration of the service.
+func (s *Service) overviewOptions(q *Query) (*OverviewOptions, error) {
+	lo, err := q.leaderboardOptions()
+	if err != nil {
+		return nil, err
+	}
+	lo.Weights = s.weights
+
+	so, err := q.submitterOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	interval, err := q.interval()
+	if err != nil {
+		return nil, err
+	}
+
+	grouping, err := s.grouping.scheme(q)
+	if err != nil {
+		return nil, err
+	}
+
+	method, err := q.outlierMethod()
+	if err != nil {
+		return nil, err
+	}
+
+	return &OverviewOptions{
+		SLA:         s.sla,
+		Leaderboard: lo,
+		Submitter:   so,
+		Grouping:    grouping,
+		Location:    s.loc,
+		Interval:    interval,
+
+		OutlierMethod:   method,
+		ExcludeOutliers: q.ExcludeOutliers,
+		CustomMetrics:   s.customMetrics,
+	}, nil
+}
+
+// ValidateQuery checks the options of the query against the configuration of the service.
+func (s *Service) ValidateQuery(q *Query) error {
+	if _, err := s.overviewOptions(q); err != nil {
+		return err
+	}
+	if _, err := q.comparisonQuery(); err != nil {
+		return err
+	}
+	if _, _, err := q.buckets(); err != nil {
+		return err
+	}
+	if _, err := q.listOptions(); err != nil {
+		return err
+	}
+	if q.Rows != "" || q.Cols != "" || q.Measure != "" || q.Sources != "" {
+		if _, err := s.pivotOptions(q); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
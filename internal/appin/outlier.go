@@ -0,0 +1,317 @@
+package appin
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	rpcstatus "google.golang.org/grpc/status"
+)
+
+// OutlierMethod is the statistical method used to detect outliers.
+type OutlierMethod string
+
+const (
+	// OutlierIQR flags values outside 1.5 interquartile ranges of the quartiles.
+	OutlierIQR OutlierMethod = "iqr"
+
+	// OutlierMAD flags values with a modified z-score over 3.5 using the median absolute deviation.
+	OutlierMAD OutlierMethod = "mad"
+)
+
+const (
+	// minOutlierSamples is the minimum number of completed items of a group to detect outliers in it.
+	minOutlierSamples = 8
+
+	// minBulkSize is the minimum number of items an executor completes in the same minute to be a bulk close.
+	minBulkSize = 3
+)
+
+// outlierMethod returns the outlier method of the query. Defaults to OutlierIQR.
+func (q *Query) outlierMethod() (OutlierMethod, error) {
+	switch v := OutlierMethod(strings.ToLower(q.OutlierMethod)); v {
+	case "":
+		return OutlierIQR, nil
+	case OutlierIQR, OutlierMAD:
+		return v, nil
+	default:
+		return "", rpcstatus.Error(codes.InvalidArgument, "outlierMethod must be one of: iqr, mad.")
+	}
+}
+
+// OutlierReport is the items with a suspicious turnaround in every stage.
+type OutlierReport struct {
+	// Method is the method used to detect outliers.
+	Method OutlierMethod `json:"method"`
+
+	// Excluded reports whether the outliers are excluded from the metrics.
+	Excluded bool `json:"excluded"`
+
+	// AppIn is the App-In outliers, the most recently completed first.
+	AppIn []*Outlier `json:"appIn"`
+
+	// CAFinal is the CA Final outliers, the most recently completed first.
+	CAFinal []*Outlier `json:"caFinal"`
+}
+
+// Outlier is an item with a suspicious turnaround.
+type Outlier struct {
+	Number      string        `json:"number"`
+	DisplayName string        `json:"displayName"`
+	Executor    string        `json:"executor"`
+	Product     string        `json:"product"`
+	CreatedAt   time.Time     `json:"createdAt"`
+	CompletedAt time.Time     `json:"completedAt"`
+	Turnaround  time.Duration `json:"turnaround"`
+
+	// Kind is "fast" or "slow", from the first of its reasons.
+	Kind string `json:"kind"`
+
+	// Reasons is why the item is flagged, in the order "product", "executor", "bulk".
+	// "product" and "executor" are outliers among the product or the executor,
+	// "bulk" is completed in the same minute as other items by the same executor.
+	Reasons []string `json:"reasons"`
+}
+
+// GetOutliers returns the items matching the query with a suspicious turnaround.
+func (s *Service) GetOutliers(ctx context.Context, q *Query) (*OutlierReport, error) {
+	opts, err := s.overviewOptions(q)
+	if err != nil {
+		return nil, err
+	}
+
+	as, ca, err := s.listStages(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	r, _, _ := newOutlierReport(as, ca, opts)
+	return r, nil
+}
+
+// outlierCandidate is a completed item checked for outliers.
+type outlierCandidate struct {
+	number      string
+	displayName string
+	executor    string
+	product     string
+	createdAt   time.Time
+	completedAt time.Time
+}
+
+func (c *outlierCandidate) turnaround() time.Duration {
+	return c.completedAt.Sub(c.createdAt)
+}
+
+// newOutlierReport detects the outliers of App-In and CA Final.
+// It also returns the index of the flagged App-In and CA Final.
+func newOutlierReport(as []*AppIn, ca []*CAFinal, opts *OverviewOptions) (*OutlierReport, map[int]bool, map[int]bool) {
+	appInOutliers := recordOutliers(records(as), opts.OutlierMethod)
+	caFinalOutliers := recordOutliers(records(ca), opts.OutlierMethod)
+
+	return &OutlierReport{
+		Method:   opts.OutlierMethod,
+		Excluded: opts.ExcludeOutliers,
+		AppIn:    sortOutliers(appInOutliers),
+		CAFinal:  sortOutliers(caFinalOutliers),
+	}, flagged(appInOutliers), flagged(caFinalOutliers)
+}
+
+// recordOutliers detects the outliers of the records of a stage.
+func recordOutliers(rs []*Record, method OutlierMethod) map[int]*Outlier {
+	candidates := make(map[int]*outlierCandidate)
+	for i, r := range rs {
+		if r.CompletedAt == nil || r.pending() {
+			continue
+		}
+		candidates[i] = &outlierCandidate{
+			number:      r.Number,
+			displayName: r.DisplayName,
+			executor:    r.Executor,
+			product:     r.Product,
+			createdAt:   r.CreatedAt,
+			completedAt: *r.CompletedAt,
+		}
+	}
+
+	return detectOutliers(candidates, method)
+}
+
+func flagged(outliers map[int]*Outlier) map[int]bool {
+	m := make(map[int]bool, len(outliers))
+	for i := range outliers {
+		m[i] = true
+	}
+	return m
+}
+
+func sortOutliers(outliers map[int]*Outlier) []*Outlier {
+	sorted := make([]*Outlier, 0, len(outliers))
+	for _, o := range outliers {
+		sorted = append(sorted, o)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].CompletedAt.After(sorted[j].CompletedAt)
+	})
+
+	return sorted
+}
+
+// detectOutliers flags the candidates that are outliers among their product or executor,
+// or that are bulk closed.
+func detectOutliers(candidates map[int]*outlierCandidate, method OutlierMethod) map[int]*Outlier {
+	outliers := make(map[int]*Outlier)
+	flag := func(i int, kind, reason string) {
+		c := candidates[i]
+		o, ok := outliers[i]
+		if !ok {
+			o = &Outlier{
+				Number:      c.number,
+				DisplayName: c.displayName,
+				Executor:    c.executor,
+				Product:     c.product,
+				CreatedAt:   c.createdAt,
+				CompletedAt: c.completedAt,
+				Turnaround:  c.turnaround(),
+				Kind:        kind,
+			}
+			outliers[i] = o
+		}
+		o.Reasons = append(o.Reasons, reason)
+	}
+
+	byProduct := make(map[string][]int)
+	byExecutor := make(map[string][]int)
+	bulks := make(map[string][]int)
+	for i, c := range candidates {
+		if c.product != "" {
+			byProduct[c.product] = append(byProduct[c.product], i)
+		}
+		if c.executor != "" {
+			byExecutor[c.executor] = append(byExecutor[c.executor], i)
+			key := c.executor + "|" + c.completedAt.Truncate(time.Minute).String()
+			bulks[key] = append(bulks[key], i)
+		}
+	}
+
+	// Reasons are checked in a fixed order so the kind of an item flagged by several reasons is stable.
+	for _, g := range []struct {
+		reason string
+		groups map[string][]int
+	}{
+		{"product", byProduct},
+		{"executor", byExecutor},
+	} {
+		reason := g.reason
+		for _, idx := range g.groups {
+			if len(idx) < minOutlierSamples {
+				continue
+			}
+
+			values := make([]float64, len(idx))
+			for k, i := range idx {
+				values[k] = logTurnaround(candidates[i].turnaround())
+			}
+
+			lower, upper := outlierBounds(values, method)
+			for k, i := range idx {
+				switch {
+				case values[k] < lower:
+					flag(i, "fast", reason)
+				case values[k] > upper:
+					flag(i, "slow", reason)
+				}
+			}
+		}
+	}
+
+	for _, idx := range bulks {
+		if len(idx) < minBulkSize {
+			continue
+		}
+		for _, i := range idx {
+			flag(i, "fast", "bulk")
+		}
+	}
+
+	return outliers
+}
+
+// logTurnaround returns the logarithm of a turnaround in seconds.
+// Turnarounds are right-skewed, so outliers are detected on a log scale.
+func logTurnaround(d time.Duration) float64 {
+	return math.Log(math.Max(d.Seconds(), 1))
+}
+
+// outlierBounds returns the range outside which values are outliers.
+func outlierBounds(values []float64, method OutlierMethod) (float64, float64) {
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	if method == OutlierMAD {
+		median := quantile(sorted, 0.5)
+		deviations := make([]float64, len(sorted))
+		for i, v := range sorted {
+			deviations[i] = math.Abs(v - median)
+		}
+		sort.Float64s(deviations)
+
+		mad := quantile(deviations, 0.5)
+		if mad == 0 {
+			return math.Inf(-1), math.Inf(1)
+		}
+
+		// |0.6745 * (x - median) / mad| > 3.5
+		spread := 3.5 * mad / 0.6745
+		return median - spread, median + spread
+	}
+
+	q1, q3 := quantile(sorted, 0.25), quantile(sorted, 0.75)
+	iqr := q3 - q1
+	return q1 - 1.5*iqr, q3 + 1.5*iqr
+}
+
+// quantile returns the q-th quantile of sorted values using linear interpolation.
+func quantile(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	pos := q * float64(len(sorted)-1)
+	lo := int(math.Floor(pos))
+	hi := int(math.Ceil(pos))
+
+	return sorted[lo] + (sorted[hi]-sorted[lo])*(pos-float64(lo))
+}
+
+// excludeOutliers returns the App-In and CA Final without their outliers if the query excludes them,
+// like the overview does, so a drill-down list matches the bucket of the overview.
+func excludeOutliers(as []*AppIn, ca []*CAFinal, q *Query) ([]*AppIn, []*CAFinal, error) {
+	if !q.ExcludeOutliers {
+		return as, ca, nil
+	}
+
+	method, err := q.outlierMethod()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	_, flaggedAs, flaggedCA := newOutlierReport(as, ca, &OverviewOptions{OutlierMethod: method, ExcludeOutliers: true})
+	return without(as, flaggedAs), without(ca, flaggedCA), nil
+}
+
+// without returns the items not in the index.
+func without[T any](items []T, index map[int]bool) []T {
+	kept := make([]T, 0, len(items))
+	for i, it := range items {
+		if !index[i] {
+			kept = append(kept, it)
+		}
+	}
+	return kept
+}
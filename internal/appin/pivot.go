@@ -0,0 +1,448 @@
+package appin
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	rpcstatus "google.golang.org/grpc/status"
+)
+
+// maxPivotCells is the maximum number of cells of a pivot table.
+const maxPivotCells = 10000
+
+// maxPivotDimensions is the maximum number of dimensions of the rows or the columns of a pivot table.
+const maxPivotDimensions = 3
+
+// PivotDimension is a dimension the items of a pivot table are grouped by.
+type PivotDimension string
+
+const (
+	PivotExecutor     PivotDimension = "executor"
+	PivotProduct      PivotDimension = "product"
+	PivotCustomerType PivotDimension = "customerType"
+	PivotStatus       PivotDimension = "status"
+	PivotCreator      PivotDimension = "creator"
+
+	// PivotDay and PivotWeek are the day and the week the item was created in the timezone of the service.
+	PivotDay  PivotDimension = "day"
+	PivotWeek PivotDimension = "week"
+
+	// PivotSource is the stage the item comes from. ex: "appin"
+	PivotSource PivotDimension = "source"
+)
+
+var pivotDimensions = []PivotDimension{PivotExecutor, PivotProduct, PivotCustomerType, PivotStatus, PivotCreator, PivotDay, PivotWeek, PivotSource}
+
+// value returns the value of the dimension of the record.
+func (d PivotDimension) value(r *Record, loc *time.Location) string {
+	switch d {
+	case PivotExecutor:
+		return r.Executor
+	case PivotProduct:
+		return r.Product
+	case PivotCustomerType:
+		return r.CustomerType
+	case PivotStatus:
+		return strings.TrimSpace(r.Status)
+	case PivotCreator:
+		return r.CreatedBy
+	case PivotDay:
+		return IntervalDay.key(r.CreatedAt, loc)
+	case PivotWeek:
+		return IntervalWeek.key(r.CreatedAt, loc)
+	case PivotSource:
+		return string(r.Stage)
+	default:
+		return ""
+	}
+}
+
+// PivotMeasure is a value calculated for each cell of a pivot table.
+// Times are in nanoseconds like every duration of the API.
+type PivotMeasure string
+
+const (
+	PivotTotal          PivotMeasure = "total"
+	PivotConverted      PivotMeasure = "converted"
+	PivotNotPassed      PivotMeasure = "notPassed"
+	PivotCancelled      PivotMeasure = "cancelled"
+	PivotReturned       PivotMeasure = "returned"
+	PivotPending        PivotMeasure = "pending"
+	PivotNeedAttention  PivotMeasure = "needAttention"
+	PivotConversionRate PivotMeasure = "conversionRate"
+	PivotAvgTime        PivotMeasure = "avgTime"
+	PivotBestTime       PivotMeasure = "bestTime"
+	PivotP50Time        PivotMeasure = "p50Time"
+	PivotP90Time        PivotMeasure = "p90Time"
+	PivotSLACompliance  PivotMeasure = "slaCompliance"
+	PivotValue          PivotMeasure = "value"
+)
+
+var pivotMeasures = []PivotMeasure{
+	PivotTotal, PivotConverted, PivotNotPassed, PivotCancelled, PivotReturned, PivotPending, PivotNeedAttention,
+	PivotConversionRate, PivotAvgTime, PivotBestTime, PivotP50Time, PivotP90Time, PivotSLACompliance, PivotValue,
+}
+
+// PivotOptions is how the items of a pivot table are grouped and measured.
+type PivotOptions struct {
+	Rows     []PivotDimension
+	Cols     []PivotDimension
+	Measures []PivotMeasure
+
+	// Sources is the stages the items come from.
+	Sources []Stage
+}
+
+// pivotOptions returns the pivot options of the query.
+// Items are counted when no measure is requested, and only App-In are pivoted unless other sources are requested.
+// More than one source must be split by the source dimension so a cell never mixes stages.
+func (s *Service) pivotOptions(q *Query) (*PivotOptions, error) {
+	o := new(PivotOptions)
+
+	dimensions := func(param, v string) ([]PivotDimension, error) {
+		ds := make([]PivotDimension, 0)
+		for _, name := range splitParam(v) {
+			d := PivotDimension(name)
+			if !slices.Contains(pivotDimensions, d) {
+				return nil, rpcstatus.Error(codes.InvalidArgument, fmt.Sprintf("%s must be any of: %s.", param, joinNames(pivotDimensions)))
+			}
+			ds = append(ds, d)
+		}
+		if len(ds) > maxPivotDimensions {
+			return nil, rpcstatus.Error(codes.InvalidArgument, fmt.Sprintf("%s must not have more than %d dimensions.", param, maxPivotDimensions))
+		}
+		return ds, nil
+	}
+
+	var err error
+	if o.Rows, err = dimensions("rows", q.Rows); err != nil {
+		return nil, err
+	}
+	if o.Cols, err = dimensions("cols", q.Cols); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[PivotDimension]bool)
+	for _, d := range append(slices.Clone(o.Rows), o.Cols...) {
+		if seen[d] {
+			return nil, rpcstatus.Error(codes.InvalidArgument, fmt.Sprintf("Dimension %s must not be used more than once.", d))
+		}
+		seen[d] = true
+	}
+
+	for _, name := range splitParam(q.Measure) {
+		m := PivotMeasure(name)
+		if !slices.Contains(pivotMeasures, m) {
+			return nil, rpcstatus.Error(codes.InvalidArgument, fmt.Sprintf("measure must be any of: %s.", joinNames(pivotMeasures)))
+		}
+		if !slices.Contains(o.Measures, m) {
+			o.Measures = append(o.Measures, m)
+		}
+	}
+	if len(o.Measures) == 0 {
+		o.Measures = []PivotMeasure{PivotTotal}
+	}
+
+	stages := s.stages.names()
+	for _, name := range splitParam(strings.ToLower(q.Sources)) {
+		st := Stage(name)
+		if !stages[st] {
+			return nil, rpcstatus.Error(codes.InvalidArgument, fmt.Sprintf("Source %s is not a stage.", name))
+		}
+		if !slices.Contains(o.Sources, st) {
+			o.Sources = append(o.Sources, st)
+		}
+	}
+	if len(o.Sources) == 0 {
+		o.Sources = []Stage{StageAppIn}
+	}
+	if len(o.Sources) > 1 && !seen[PivotSource] {
+		return nil, rpcstatus.Error(codes.InvalidArgument, "source must be a row or a column when pivoting more than one source.")
+	}
+
+	return o, nil
+}
+
+// splitParam returns the non-empty comma-separated values of a query parameter.
+func splitParam(v string) []string {
+	vs := make([]string, 0)
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			vs = append(vs, s)
+		}
+	}
+
+	return vs
+}
+
+func joinNames[T ~string](vs []T) string {
+	names := make([]string, 0, len(vs))
+	for _, v := range vs {
+		names = append(names, string(v))
+	}
+
+	return strings.Join(names, ", ")
+}
+
+// PivotTable is the measures of the items grouped by the row and column dimensions.
+type PivotTable struct {
+	Rows     []PivotDimension `json:"rows"`
+	Cols     []PivotDimension `json:"cols"`
+	Measures []PivotMeasure   `json:"measures"`
+	Sources  []Stage          `json:"sources"`
+
+	// RowKeys and ColKeys is the values of the row and column dimensions of each row and column, sorted.
+	// A single key without values when no dimension is requested.
+	RowKeys [][]string `json:"rowKeys"`
+	ColKeys [][]string `json:"colKeys"`
+
+	// Cells is the cell of each row and column. Cells[i][j] is the cell of RowKeys[i] and ColKeys[j].
+	Cells [][]*PivotCell `json:"cells"`
+
+	// RowTotals and ColTotals is the cell of each row and column across the other dimensions.
+	RowTotals []*PivotCell `json:"rowTotals"`
+	ColTotals []*PivotCell `json:"colTotals"`
+
+	// Total is the cell of every item.
+	Total *PivotCell `json:"total"`
+}
+
+// PivotCell is the measures of the items of a cell.
+type PivotCell struct {
+	// Count is the number of items of the cell.
+	Count int64 `json:"count"`
+
+	// Values is the value of each requested measure.
+	// conversionRate and avgTime are left out of the totals that mix stages,
+	// since the stages do not count the same items as processed.
+	Values map[PivotMeasure]float64 `json:"values"`
+}
+
+// GetPivot returns the pivot table of the items matching the query.
+func (s *Service) GetPivot(ctx context.Context, q *Query) (*PivotTable, error) {
+	opts, err := s.pivotOptions(q)
+	if err != nil {
+		return nil, err
+	}
+
+	rs, err := s.listSources(ctx, q, opts.Sources)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.newPivotTable(rs, opts)
+}
+
+// listSources lists the records of the stages matching the query.
+func (s *Service) listSources(ctx context.Context, q *Query, sources []Stage) ([]*Record, error) {
+	rs := make([]*Record, 0)
+
+	switch {
+	case slices.Contains(sources, StageCAFinal) && slices.Contains(sources, StageAppIn):
+		as, ca, err := s.listStages(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		rs = append(rs, records(as)...)
+		rs = append(rs, records(ca)...)
+
+	case slices.Contains(sources, StageCAFinal):
+		ca, err := s.listCAFinalsWithProducts(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		rs = append(rs, records(ca)...)
+
+	case slices.Contains(sources, StageAppIn):
+		as, err := s.listAppIns(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		rs = append(rs, records(as)...)
+	}
+
+	for _, st := range s.stages {
+		if !slices.Contains(sources, st.Name) {
+			continue
+		}
+
+		srs, err := s.listRecords(ctx, st, q)
+		if err != nil {
+			return nil, err
+		}
+		rs = append(rs, srs...)
+	}
+
+	return rs, nil
+}
+
+// stage returns the config of the stage.
+func (s *Service) stage(name Stage) *StageConfig {
+	switch name {
+	case StageAppIn:
+		return appInStage
+	case StageCAFinal:
+		return caFinalStage
+	}
+
+	for _, st := range s.stages {
+		if st.Name == name {
+			return st
+		}
+	}
+
+	return nil
+}
+
+func (s *Service) newPivotTable(rs []*Record, opts *PivotOptions) (*PivotTable, error) {
+	key := func(r *Record, ds []PivotDimension) string {
+		vs := make([]string, 0, len(ds))
+		for _, d := range ds {
+			vs = append(vs, d.value(r, s.loc))
+		}
+		return strings.Join(vs, "\x00")
+	}
+
+	rows := make(map[string][]*Record)
+	cols := make(map[string][]*Record)
+	cells := make(map[[2]string][]*Record)
+	for _, r := range rs {
+		rk, ck := key(r, opts.Rows), key(r, opts.Cols)
+		rows[rk] = append(rows[rk], r)
+		cols[ck] = append(cols[ck], r)
+		cells[[2]string{rk, ck}] = append(cells[[2]string{rk, ck}], r)
+	}
+
+	if len(rows)*len(cols) > maxPivotCells {
+		return nil, rpcstatus.Error(codes.InvalidArgument, fmt.Sprintf("The pivot table must not have more than %d cells. Use fewer dimensions or a shorter period.", maxPivotCells))
+	}
+
+	rowKeys, colKeys := sortedKeys(rows), sortedKeys(cols)
+	if len(opts.Rows) == 0 {
+		rowKeys = []string{""}
+	}
+	if len(opts.Cols) == 0 {
+		colKeys = []string{""}
+	}
+
+	split := func(k string, ds []PivotDimension) []string {
+		if len(ds) == 0 {
+			return []string{}
+		}
+		return strings.Split(k, "\x00")
+	}
+
+	p := &PivotTable{
+		Rows:      opts.Rows,
+		Cols:      opts.Cols,
+		Measures:  opts.Measures,
+		Sources:   opts.Sources,
+		RowKeys:   make([][]string, 0, len(rowKeys)),
+		ColKeys:   make([][]string, 0, len(colKeys)),
+		Cells:     make([][]*PivotCell, 0, len(rowKeys)),
+		RowTotals: make([]*PivotCell, 0, len(rowKeys)),
+		ColTotals: make([]*PivotCell, 0, len(colKeys)),
+		Total:     s.newPivotCell(rs, opts.Measures),
+	}
+
+	for _, ck := range colKeys {
+		p.ColKeys = append(p.ColKeys, split(ck, opts.Cols))
+		p.ColTotals = append(p.ColTotals, s.newPivotCell(cols[ck], opts.Measures))
+	}
+
+	for _, rk := range rowKeys {
+		p.RowKeys = append(p.RowKeys, split(rk, opts.Rows))
+		p.RowTotals = append(p.RowTotals, s.newPivotCell(rows[rk], opts.Measures))
+
+		row := make([]*PivotCell, 0, len(colKeys))
+		for _, ck := range colKeys {
+			row = append(row, s.newPivotCell(cells[[2]string{rk, ck}], opts.Measures))
+		}
+		p.Cells = append(p.Cells, row)
+	}
+
+	return p, nil
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	return keys
+}
+
+// newPivotCell measures the records of a cell with the conversion rules of their stage.
+// The measures that depend on the rules of a stage are left out of the totals across several stages.
+func (s *Service) newPivotCell(rs []*Record, measures []PivotMeasure) *PivotCell {
+	c := &PivotCell{
+		Count:  int64(len(rs)),
+		Values: make(map[PivotMeasure]float64, len(measures)),
+	}
+
+	st, mixed := appInStage, false
+	if len(rs) > 0 {
+		st = s.stage(rs[0].Stage)
+		mixed = slices.ContainsFunc(rs, func(r *Record) bool { return r.Stage != rs[0].Stage })
+	}
+
+	conversion := newConversion(st, rs, s.sla)
+	durations := sortDurations(convertedDurations(rs))
+
+	for _, m := range measures {
+		if mixed && (m == PivotConversionRate || m == PivotAvgTime) {
+			continue
+		}
+
+		var v float64
+		switch m {
+		case PivotTotal:
+			v = float64(conversion.Total)
+		case PivotConverted:
+			v = float64(conversion.Converted)
+		case PivotNotPassed:
+			v = float64(conversion.NotPassed)
+		case PivotCancelled:
+			v = float64(conversion.Cancelled)
+		case PivotReturned:
+			v = float64(conversion.Returned)
+		case PivotPending:
+			for _, r := range rs {
+				if r.pending() {
+					v++
+				}
+			}
+		case PivotNeedAttention:
+			v = float64(conversion.NeedAttention)
+		case PivotConversionRate:
+			v = float64(conversion.Rate)
+		case PivotAvgTime:
+			v = float64(conversion.AverageTime)
+		case PivotBestTime:
+			v = float64(conversion.BestTime)
+		case PivotP50Time:
+			v = float64(percentile(durations, 50))
+		case PivotP90Time:
+			v = float64(percentile(durations, 90))
+		case PivotSLACompliance:
+			v = float64(newSLAReport(rs, s.sla).Compliance)
+		case PivotValue:
+			var value Decimal
+			for _, r := range rs {
+				if r.converted() && r.Value != nil {
+					value += *r.Value
+				}
+			}
+			v = value.Float64()
+		}
+		c.Values[m] = v
+	}
+
+	return c
+}
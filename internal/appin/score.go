@@ -0,0 +1,192 @@
+package appin
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// ScoreWeights is the weight of each input of the composite performance score.
+// Inputs are normalized across the peer group before being weighted.
+type ScoreWeights struct {
+	// Volume is the weight of the total count.
+	Volume float64 `json:"volume"`
+
+	// ConversionRate is the weight of the conversion rate.
+	ConversionRate float64 `json:"conversionRate"`
+
+	// MedianTurnaround is the weight of the median time used for converted items. Shorter is better.
+	MedianTurnaround float64 `json:"medianTurnaround"`
+
+	// SLACompliance is the weight of the percentage of items within their SLA.
+	SLACompliance float64 `json:"slaCompliance"`
+
+	// ValueFinanced is the weight of the finance amount of converted items.
+	ValueFinanced float64 `json:"valueFinanced"`
+}
+
+// DefaultScoreWeights returns the weights used when none is configured. Every input weighs the same.
+func DefaultScoreWeights() *ScoreWeights {
+	return &ScoreWeights{
+		Volume:           1,
+		ConversionRate:   1,
+		MedianTurnaround: 1,
+		SLACompliance:    1,
+		ValueFinanced:    1,
+	}
+}
+
+// ReadScoreWeightsFile reads score weights from a JSON file.
+func ReadScoreWeightsFile(name string) (*ScoreWeights, error) {
+	byt, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read score weights: %w", err)
+	}
+
+	w := new(ScoreWeights)
+	if err := json.Unmarshal(byt, w); err != nil {
+		return nil, fmt.Errorf("failed to parse score weights: %w", err)
+	}
+
+	return w, w.Validate()
+}
+
+func (w *ScoreWeights) Validate() error {
+	if w.Volume < 0 || w.ConversionRate < 0 || w.MedianTurnaround < 0 || w.SLACompliance < 0 || w.ValueFinanced < 0 {
+		return fmt.Errorf("score weights must not be negative")
+	}
+	if w.total() == 0 {
+		return fmt.Errorf("score weights must not all be zero")
+	}
+
+	return nil
+}
+
+func (w *ScoreWeights) total() float64 {
+	return w.Volume + w.ConversionRate + w.MedianTurnaround + w.SLACompliance + w.ValueFinanced
+}
+
+// Score is the composite performance score of a performer from 0 to 100.
+type Score struct {
+	// Total is the weighted average of the normalized inputs times 100.
+	Total float64 `json:"total"`
+
+	// Components is the breakdown of the score by input.
+	Components []*ScoreComponent `json:"components"`
+}
+
+// ScoreComponent is an input of the composite performance score.
+type ScoreComponent struct {
+	// Name is the name of the input. ex: "conversionRate"
+	Name string `json:"name"`
+
+	// Value is the raw value of the input.
+	Value float64 `json:"value"`
+
+	// Normalized is the value scaled between the worst (0) and the best (1) of the peer group.
+	Normalized float64 `json:"normalized"`
+
+	// Weight is the weight of the input.
+	Weight float64 `json:"weight"`
+
+	// Contribution is the points the input adds to the total.
+	Contribution float64 `json:"contribution"`
+}
+
+// scoreInput is an input of the score read from a performer.
+type scoreInput struct {
+	name   string
+	weight float64
+
+	// value returns the input of a performer and whether it is known.
+	// An unknown input is normalized to the worst.
+	value func(p *performerMetric) (float64, bool)
+
+	// lowerIsBetter inverts the normalization.
+	lowerIsBetter bool
+}
+
+// setScores sets the composite score of each performer normalized across the performers.
+func setScores(performers []*performerMetric, w *ScoreWeights) {
+	inputs := []*scoreInput{
+		{
+			name:   "volume",
+			weight: w.Volume,
+			value:  func(p *performerMetric) (float64, bool) { return float64(p.Conversion.Total), true },
+		},
+		{
+			name:   "conversionRate",
+			weight: w.ConversionRate,
+			value:  func(p *performerMetric) (float64, bool) { return float64(p.Conversion.Rate), true },
+		},
+		{
+			name:          "medianTurnaround",
+			weight:        w.MedianTurnaround,
+			value:         func(p *performerMetric) (float64, bool) { return float64(p.P50), p.P50 > 0 },
+			lowerIsBetter: true,
+		},
+		{
+			name:   "slaCompliance",
+			weight: w.SLACompliance,
+			value:  func(p *performerMetric) (float64, bool) { return float64(p.SLACompliance), true },
+		},
+		{
+			name:   "valueFinanced",
+			weight: w.ValueFinanced,
+			value:  func(p *performerMetric) (float64, bool) { return p.ValueFinanced.Float64(), true },
+		},
+	}
+
+	for _, p := range performers {
+		p.Score = &Score{Components: make([]*ScoreComponent, 0, len(inputs))}
+	}
+
+	total := w.total()
+	for _, in := range inputs {
+		var lo, hi float64
+		first := true
+		for _, p := range performers {
+			v, ok := in.value(p)
+			if !ok {
+				continue
+			}
+			if first || v < lo {
+				lo = v
+			}
+			if first || v > hi {
+				hi = v
+			}
+			first = false
+		}
+
+		for _, p := range performers {
+			v, ok := in.value(p)
+
+			var n float64
+			switch {
+			case !ok:
+				n = 0
+			case hi == lo:
+				n = 1
+			case in.lowerIsBetter:
+				n = (hi - v) / (hi - lo)
+			default:
+				n = (v - lo) / (hi - lo)
+			}
+
+			contribution := 0.0
+			if total > 0 {
+				contribution = n * in.weight / total * 100
+			}
+
+			p.Score.Total += contribution
+			p.Score.Components = append(p.Score.Components, &ScoreComponent{
+				Name:         in.name,
+				Value:        v,
+				Normalized:   n,
+				Weight:       in.weight,
+				Contribution: contribution,
+			})
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 
@@ -14,6 +15,8 @@ import (
 	"github.com/microsoftgraph/msgraph-sdk-go/models"
 	"github.com/microsoftgraph/msgraph-sdk-go/sites"
 	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	rpcstatus "google.golang.org/grpc/status"
 
 	"golang.org/x/sync/errgroup"
 )
@@ -24,6 +27,16 @@ type Service struct {
 	siteID        string
 	listID        string
 	caFinalListID string
+	sla           *SLAPolicy
+	loc           *time.Location
+	taxonomy      StatusTaxonomy
+	grouping      GroupingSchemes
+	weights       *ScoreWeights
+	customMetrics CustomMetrics
+	caColumns     *CAFinalColumns
+	stages        StageConfigs
+	executors     *ExecutorDirectory
+	catalog       []*Metric
 }
 
 func NewService(_ context.Context, config *Config) (*Service, error) {
@@ -44,12 +57,65 @@ func NewService(_ context.Context, config *Config) (*Service, error) {
 		return nil, fmt.Errorf("failed to create client: %w", err)
 	}
 
+	sla := config.SLAPolicy
+	if sla == nil {
+		sla = DefaultSLAPolicy()
+	}
+
+	loc := config.Location
+	if loc == nil {
+		loc = time.Local
+	}
+
+	taxonomy := config.StatusTaxonomy
+	if taxonomy == nil {
+		taxonomy = DefaultStatusTaxonomy()
+	}
+
+	taxonomy, err = taxonomy.withStages(config.Stages)
+	if err != nil {
+		return nil, err
+	}
+
+	grouping := config.GroupingSchemes
+	if grouping == nil {
+		grouping = DefaultGroupingSchemes()
+	}
+
+	caColumns := config.CAFinalColumns
+	if caColumns == nil {
+		caColumns = new(CAFinalColumns)
+	}
+
+	executors := config.Executors
+	if executors == nil {
+		executors, err = NewExecutorDirectory("")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	weights := config.ScoreWeights
+	if weights == nil {
+		weights = DefaultScoreWeights()
+	}
+
 	return &Service{
 		client:        client,
 		siteID:        config.SiteID,
 		listID:        config.ListID,
 		caFinalListID: config.CAFinalListID,
 		zlog:          config.Zlog,
+		sla:           sla,
+		loc:           loc,
+		taxonomy:      taxonomy,
+		grouping:      grouping,
+		weights:       weights,
+		customMetrics: config.CustomMetrics,
+		caColumns:     caColumns,
+		stages:        config.Stages,
+		executors:     executors,
+		catalog:       newMetricCatalog(config.Stages, config.CustomMetrics),
 	}, nil
 }
 
@@ -62,6 +128,41 @@ type Config struct {
 	ListID        string
 	CAFinalListID string
 	Scopes        []string
+
+	// SLAPolicy is the SLA policy of every stage.
+	// DefaultSLAPolicy is used if nil.
+	SLAPolicy *SLAPolicy
+
+	// Location is the timezone of days, hours and periods.
+	// time.Local is used if nil.
+	Location *time.Location
+
+	// StatusTaxonomy maps the raw statuses of every stage to an outcome.
+	// DefaultStatusTaxonomy is used if nil.
+	StatusTaxonomy StatusTaxonomy
+
+	// GroupingSchemes is the product grouping schemes a request can pick from.
+	// DefaultGroupingSchemes is used if nil.
+	GroupingSchemes GroupingSchemes
+
+	// ScoreWeights is the weights of the composite performance score.
+	// DefaultScoreWeights is used if nil.
+	ScoreWeights *ScoreWeights
+
+	// CustomMetrics is the user-defined metrics of the overview.
+	CustomMetrics CustomMetrics
+
+	// CAFinalColumns is the product, customer type and reason columns of the CA Final list.
+	// The product and customer type are taken from App-In if nil.
+	CAFinalColumns *CAFinalColumns
+
+	// Stages is the stages after CA Final, in pipeline order.
+	// Each stage needs a taxonomy in its config or in StatusTaxonomy.
+	Stages StageConfigs
+
+	// Executors merges the raw executor names into canonical executors.
+	// Raw names are only trimmed if nil.
+	Executors *ExecutorDirectory
 }
 
 func (c Config) Validate() error {
@@ -86,35 +187,277 @@ func (c Config) Validate() error {
 	if c.CAFinalListID == "" {
 		return fmt.Errorf("caFinalListID is empty")
 	}
+	if err := c.Stages.Validate(); err != nil {
+		return err
+	}
+	if c.SLAPolicy != nil {
+		if err := c.SLAPolicy.Validate(); err != nil {
+			return err
+		}
+		if err := c.SLAPolicy.validateStages(c.Stages); err != nil {
+			return err
+		}
+	}
+	if c.StatusTaxonomy != nil {
+		if err := c.StatusTaxonomy.Validate(); err != nil {
+			return err
+		}
+	}
+	if c.GroupingSchemes != nil {
+		if err := c.GroupingSchemes.Validate(); err != nil {
+			return err
+		}
+	}
+	if c.ScoreWeights != nil {
+		if err := c.ScoreWeights.Validate(); err != nil {
+			return err
+		}
+	}
+	if err := c.CustomMetrics.Validate(); err != nil {
+		return err
+	}
 
 	return nil
 }
 
 type ListAppInResult struct {
 	AppIns []*AppIn `json:"appIns"`
+
+	// Total is the number of App-In matching the query across every page.
+	Total int64 `json:"total"`
 }
 
+// ListAppIns lists a page of the App-In matching the query. See ListOptions.
+// With a convertedBucket or pendingBucket only the App-In of the time interval are listed, the oldest first.
+// With excludeOutliers the outliers are not listed, like in the overview.
 func (s *Service) ListAppIns(ctx context.Context, q *Query) (*ListAppInResult, error) {
+	if _, _, err := q.buckets(); err != nil {
+		return nil, err
+	}
+
+	lo, err := q.listOptions()
+	if err != nil {
+		return nil, err
+	}
+	lo.Executor, _ = s.executors.lookup(lo.Executor)
+
 	as, err := s.listAppIns(ctx, q)
 	if err != nil {
 		return nil, err
 	}
 
+	as, _, err = excludeOutliers(as, nil, q)
+	if err != nil {
+		return nil, err
+	}
+
+	as, err = appInsInBucket(as, q)
+	if err != nil {
+		return nil, err
+	}
+
+	page, total := listPage(as, lo)
 	return &ListAppInResult{
-		AppIns: as,
+		AppIns: page,
+		Total:  total,
 	}, nil
 }
 
+type ListCAFinalResult struct {
+	CAFinals []*CAFinal `json:"caFinals"`
+
+	// Total is the number of CA Final matching the query across every page.
+	Total int64 `json:"total"`
+}
+
+// ListCAFinals lists a page of the CA Final matching the query. See ListOptions.
+// With a convertedBucket or pendingBucket only the CA Final of the time interval are listed, the oldest first.
+// With excludeOutliers the outliers are not listed, like in the overview.
+func (s *Service) ListCAFinals(ctx context.Context, q *Query) (*ListCAFinalResult, error) {
+	if _, _, err := q.buckets(); err != nil {
+		return nil, err
+	}
+
+	lo, err := q.listOptions()
+	if err != nil {
+		return nil, err
+	}
+	lo.Executor, _ = s.executors.lookup(lo.Executor)
+
+	cs, err := s.listCAFinalsWithProducts(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	_, cs, err = excludeOutliers(nil, cs, q)
+	if err != nil {
+		return nil, err
+	}
+
+	cs, err = caFinalsInBucket(cs, q)
+	if err != nil {
+		return nil, err
+	}
+
+	page, total := listPage(cs, lo)
+	return &ListCAFinalResult{
+		CAFinals: page,
+		Total:    total,
+	}, nil
+}
+
+// GetCAFinalOverview returns the overview of the CA Final matching the query.
+func (s *Service) GetCAFinalOverview(ctx context.Context, q *Query) (*StageOverview, error) {
+	cq, err := q.comparisonQuery()
+	if err != nil {
+		return nil, err
+	}
+
+	opts, err := s.overviewOptions(q)
+	if err != nil {
+		return nil, err
+	}
+
+	var ca, pca []*CAFinal
+	g, gctx := errgroup.WithContext(ctx)
+
+	g.Go(func() (err error) {
+		ca, err = s.listCAFinalsWithProducts(gctx, q)
+		return
+	})
+
+	if cq != nil {
+		g.Go(func() (err error) {
+			pca, err = s.listCAFinalsWithProducts(gctx, cq)
+			return
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	if opts.ExcludeOutliers {
+		_, _, flagged := newOutlierReport(nil, ca, opts)
+		ca = without(ca, flagged)
+	}
+
+	o := newStageOverview(caFinalStage, records(ca), opts)
+	if cq != nil {
+		if opts.ExcludeOutliers {
+			_, _, flagged := newOutlierReport(nil, pca, opts)
+			pca = without(pca, flagged)
+		}
+
+		o.SetComparison(cq, records(pca), opts)
+	}
+
+	return o, nil
+}
+
 func (s *Service) GetOverview(ctx context.Context, q *Query) (*Overview, error) {
+	cq, err := q.comparisonQuery()
+	if err != nil {
+		return nil, err
+	}
+
+	opts, err := s.overviewOptions(q)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		as, pas []*AppIn
+		ca, pca []*CAFinal
+	)
+
+	g, gctx := errgroup.WithContext(ctx)
+
+	g.Go(func() (err error) {
+		as, ca, err = s.listStages(gctx, q)
+		return
+	})
+
+	if cq != nil {
+		g.Go(func() (err error) {
+			pas, pca, err = s.listStages(gctx, cq)
+			return
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	outliers, flaggedAs, flaggedCA := newOutlierReport(as, ca, opts)
+	if opts.ExcludeOutliers {
+		as, ca = without(as, flaggedAs), without(ca, flaggedCA)
+	}
+
+	o := newOverview(as, opts)
+	o.SetCAFinal(ca, opts)
+	o.Outliers = outliers
+	o.CustomMetrics = opts.CustomMetrics.evaluate(as, ca)
+
+	if cq != nil {
+		if opts.ExcludeOutliers {
+			_, flaggedAs, flaggedCA := newOutlierReport(pas, pca, opts)
+			pas, pca = without(pas, flaggedAs), without(pca, flaggedCA)
+		}
+
+		o.SetComparison(cq, pas, pca, opts)
+	}
+
+	rs, err := s.setStages(ctx, o, q, cq, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	o.UnmappedStatuses = newUnmappedStatuses(slices.Concat(append([][]*Record{records(as), records(ca)}, rs...)...))
+	if len(o.UnmappedStatuses) > 0 {
+		s.zlog.Warn("unmapped statuses found", zap.Any("statuses", o.UnmappedStatuses))
+	}
+
+	return o, nil
+}
+
+// GetSLAReport returns the SLA compliance of App-In and CA Final matching the query.
+func (s *Service) GetSLAReport(ctx context.Context, q *Query) (*SLAOverview, error) {
+	as, ca, err := s.listStages(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SLAOverview{
+		AppIn:   newSLAReport(records(as), s.sla),
+		CAFinal: newSLAReport(records(ca), s.sla),
+	}, nil
+}
+
+// listStages lists App-In and CA Final records matching the query concurrently.
+func (s *Service) listStages(ctx context.Context, q *Query) ([]*AppIn, []*CAFinal, error) {
+	return s.listStagesByFilter(ctx, q, q.String(), q.ToCAFinalQueryString())
+}
+
+// listOpenStages lists the App-In and CA Final open at any time of the query period concurrently:
+// created before the period ends and not completed before it starts, whenever they were created.
+func (s *Service) listOpenStages(ctx context.Context, q *Query) ([]*AppIn, []*CAFinal, error) {
+	after, before := q.period()
+	return s.listStagesByFilter(ctx, q, q.openAppInFilter(after, before), q.openCAFinalFilter(after, before))
+}
+
+// listStagesByFilter lists the App-In and CA Final matching the OData filters concurrently.
+// The CA Final are filtered by the product of the query.
+func (s *Service) listStagesByFilter(ctx context.Context, q *Query, appInFilter, caFinalFilter string) ([]*AppIn, []*CAFinal, error) {
 	var (
 		as []*AppIn
 		ca []*CAFinal
 	)
 
-	g, ctx := errgroup.WithContext(ctx)
+	g, gctx := errgroup.WithContext(ctx)
 
 	g.Go(func() (err error) {
-		as, err = s.listAppIns(ctx, q)
+		as, err = s.listAppInsByFilter(gctx, appInFilter)
 		if err != nil {
 			return err
 		}
@@ -123,7 +466,7 @@ func (s *Service) GetOverview(ctx context.Context, q *Query) (*Overview, error)
 	})
 
 	g.Go(func() (err error) {
-		ca, err = s.listCAFinals(ctx, q)
+		ca, err = s.listCAFinalsByFilter(gctx, caFinalFilter)
 		if err != nil {
 			return err
 		}
@@ -133,23 +476,33 @@ func (s *Service) GetOverview(ctx context.Context, q *Query) (*Overview, error)
 
 	if err := g.Wait(); err != nil {
 		s.zlog.Error("g.Wait failed", zap.Error(err))
-		return nil, err
+		return nil, nil, err
 	}
 
-	o := newOverview(as)
-	o.SetCAFinal(ca)
+	if s.caColumns.joined() {
+		if err := s.joinAppInsByLoan(ctx, ca, as); err != nil {
+			s.zlog.Error("failed to join App-In of CA Final", zap.Error(err))
+			return nil, nil, err
+		}
+	}
+	ca = caFinalsOfProduct(ca, q.Product)
 
-	return o, nil
+	return as, ca, nil
 }
 
 func (s *Service) listAppIns(ctx context.Context, q *Query) ([]*AppIn, error) {
+	return s.listAppInsByFilter(ctx, q.String())
+}
+
+// listAppInsByFilter lists the App-In matching the OData filter.
+func (s *Service) listAppInsByFilter(ctx context.Context, filter string) ([]*AppIn, error) {
 	zlog := s.zlog.With(
-		zap.String("method", "listAppIns"),
-		zap.Any("query", q),
+		zap.String("method", "listAppInsByFilter"),
+		zap.String("filter", filter),
 	)
 
 	as := make([]*AppIn, 0)
-	config := newReqConfig(q)
+	config := newReqConfig(filter)
 
 	res, err := s.client.Sites().
 		BySiteId(s.siteID).
@@ -191,7 +544,10 @@ func (s *Service) listAppIns(ctx context.Context, q *Query) ([]*AppIn, error) {
 			return false
 		}
 
-		as = append(as, newAppInFromRawAppIn(a))
+		app := newAppInFromRawAppIn(a)
+		app.Executor = s.executors.resolve(app.Executor)
+		app.setOutcome(s.taxonomy)
+		as = append(as, app)
 		return true
 	}); err != nil {
 		zlog.Error("failed to iterate page", zap.Error(err))
@@ -202,13 +558,18 @@ func (s *Service) listAppIns(ctx context.Context, q *Query) ([]*AppIn, error) {
 }
 
 func (s *Service) listCAFinals(ctx context.Context, q *Query) ([]*CAFinal, error) {
+	return s.listCAFinalsByFilter(ctx, q.ToCAFinalQueryString())
+}
+
+// listCAFinalsByFilter lists the CA Final matching the OData filter.
+func (s *Service) listCAFinalsByFilter(ctx context.Context, filter string) ([]*CAFinal, error) {
 	zlog := s.zlog.With(
-		zap.String("method", "listCAFinals"),
-		zap.Any("query", q),
+		zap.String("method", "listCAFinalsByFilter"),
+		zap.String("filter", filter),
 	)
 
 	as := make([]*CAFinal, 0)
-	config := newCAFinalReqConfig(q)
+	config := newCAFinalReqConfig(filter, s.caColumns)
 
 	res, err := s.client.Sites().
 		BySiteId(s.siteID).
@@ -250,7 +611,11 @@ func (s *Service) listCAFinals(ctx context.Context, q *Query) ([]*CAFinal, error
 			return false
 		}
 
-		as = append(as, newAppInFromRawCAFinal(a))
+		c := newAppInFromRawCAFinal(a)
+		c.Executor = s.executors.resolve(c.Executor)
+		c.setColumns(s.caColumns, pageItem.GetFields().GetAdditionalData())
+		c.setOutcome(s.taxonomy)
+		as = append(as, c)
 		return true
 	}); err != nil {
 		zlog.Error("failed to iterate page", zap.Error(err))
@@ -260,12 +625,12 @@ func (s *Service) listCAFinals(ctx context.Context, q *Query) ([]*CAFinal, error
 	return as, nil
 }
 
-func newQueryParams(q *Query) *sites.ItemListsItemItemsRequestBuilderGetQueryParameters {
+func newQueryParams(filter string) *sites.ItemListsItemItemsRequestBuilderGetQueryParameters {
 	return &sites.ItemListsItemItemsRequestBuilderGetQueryParameters{
 		Expand: []string{
 			`fields($select=Created,Title,LOFacility,ServiceType,CustomerType,Gender,ENGfullname,Status,CompletedDateTime,Creditamount,Instalmentperiod,AssignedTo,Author)`,
 		},
-		Filter: to.Ptr(q.String()),
+		Filter: to.Ptr(filter),
 		Orderby: []string{
 			"fields/Created desc",
 		},
@@ -273,12 +638,13 @@ func newQueryParams(q *Query) *sites.ItemListsItemItemsRequestBuilderGetQueryPar
 	}
 }
 
-func newCAFinalQueryParams(q *Query) *sites.ItemListsItemItemsRequestBuilderGetQueryParameters {
+func newCAFinalQueryParams(filter string, cols *CAFinalColumns) *sites.ItemListsItemItemsRequestBuilderGetQueryParameters {
+	fields := append([]string{"FL", "Fullname", "CAFinalAssign", "CaseStatus", "FinalEndTime", "AssignTime"}, cols.names()...)
 	return &sites.ItemListsItemItemsRequestBuilderGetQueryParameters{
 		Expand: []string{
-			`fields($select=FL,Fullname,CAFinalAssign,CaseStatus,FinalEndTime,AssignTime)`,
+			`fields($select=` + strings.Join(fields, ",") + `)`,
 		},
-		Filter: to.Ptr(q.ToCAFinalQueryString()),
+		Filter: to.Ptr(filter),
 		Orderby: []string{
 			"fields/Created desc",
 		},
@@ -286,15 +652,15 @@ func newCAFinalQueryParams(q *Query) *sites.ItemListsItemItemsRequestBuilderGetQ
 	}
 }
 
-func newCAFinalReqConfig(q *Query) *sites.ItemListsItemItemsRequestBuilderGetRequestConfiguration {
+func newCAFinalReqConfig(filter string, cols *CAFinalColumns) *sites.ItemListsItemItemsRequestBuilderGetRequestConfiguration {
 	return &sites.ItemListsItemItemsRequestBuilderGetRequestConfiguration{
-		QueryParameters: newCAFinalQueryParams(q),
+		QueryParameters: newCAFinalQueryParams(filter, cols),
 	}
 }
 
-func newReqConfig(q *Query) *sites.ItemListsItemItemsRequestBuilderGetRequestConfiguration {
+func newReqConfig(filter string) *sites.ItemListsItemItemsRequestBuilderGetRequestConfiguration {
 	return &sites.ItemListsItemItemsRequestBuilderGetRequestConfiguration{
-		QueryParameters: newQueryParams(q),
+		QueryParameters: newQueryParams(filter),
 	}
 }
 
@@ -302,6 +668,118 @@ type Query struct {
 	CreatedAfter  time.Time `json:"createdAfter" query:"createdAfter"`
 	CreatedBefore time.Time `json:"createdBefore" query:"createdBefore"`
 	Product       string    `json:"product" query:"product"`
+
+	// Compare is the comparison mode of the overview.
+	// Use "previous" to compare against the equivalent previous period
+	// or "custom" to compare against CompareAfter and CompareBefore.
+	Compare       string    `json:"compare" query:"compare"`
+	CompareAfter  time.Time `json:"compareAfter" query:"compareAfter"`
+	CompareBefore time.Time `json:"compareBefore" query:"compareBefore"`
+
+	// Leaderboard options. See LeaderboardOptions.
+	LeaderboardLimit     int    `json:"leaderboardLimit" query:"leaderboardLimit"`
+	LeaderboardOffset    int    `json:"leaderboardOffset" query:"leaderboardOffset"`
+	LeaderboardSort      string `json:"leaderboardSort" query:"leaderboardSort"`
+	LeaderboardMinVolume int64  `json:"leaderboardMinVolume" query:"leaderboardMinVolume"`
+	LeaderboardRanking   string `json:"leaderboardRanking" query:"leaderboardRanking"`
+
+	// Submitter options. See SubmitterOptions.
+	SubmitterLimit     int   `json:"submitterLimit" query:"submitterLimit"`
+	SubmitterOffset    int   `json:"submitterOffset" query:"submitterOffset"`
+	SubmitterMinVolume int64 `json:"submitterMinVolume" query:"submitterMinVolume"`
+
+	// Interval is the length of the periods of time series. See Interval.
+	Interval string `json:"interval" query:"interval"`
+
+	// Grouping is the name of the product grouping scheme. See GroupingSchemes.
+	Grouping string `json:"grouping" query:"grouping"`
+
+	// OutlierMethod is the method used to detect turnaround outliers. See OutlierMethod.
+	OutlierMethod string `json:"outlierMethod" query:"outlierMethod"`
+
+	// ExcludeOutliers excludes the turnaround outliers from the overview metrics.
+	ExcludeOutliers bool `json:"excludeOutliers" query:"excludeOutliers"`
+
+	// Horizon is the number of intervals to forecast.
+	Horizon int `json:"horizon" query:"horizon"`
+
+	// ConvertedBucket and PendingBucket list the items of a time interval of the overview. ex: "5h+"
+	// See TimeInterval.Key.
+	ConvertedBucket string `json:"convertedBucket" query:"convertedBucket"`
+	PendingBucket   string `json:"pendingBucket" query:"pendingBucket"`
+
+	// List options of the App-In and CA Final lists. See ListOptions.
+	Executor string `json:"executor" query:"executor"`
+	Status   string `json:"status" query:"status"`
+	Outcome  string `json:"outcome" query:"outcome"`
+	Search   string `json:"search" query:"search"`
+
+	// Sort is the field the list is sorted by. Prefix with "-" to sort descending. ex: "-age"
+	Sort   string `json:"sort" query:"sort"`
+	Limit  int    `json:"limit" query:"limit"`
+	Offset int    `json:"offset" query:"offset"`
+
+	// Pivot options. Comma-separated. ex: rows=executor&cols=product&measure=converted,avgTime
+	// See PivotOptions.
+	Rows    string `json:"rows" query:"rows"`
+	Cols    string `json:"cols" query:"cols"`
+	Measure string `json:"measure" query:"measure"`
+	Sources string `json:"sources" query:"sources"`
+}
+
+// period returns the effective created range of the query.
+// When no range is given the query covers the last month.
+func (q *Query) period() (time.Time, time.Time) {
+	after, before := q.CreatedAfter, q.CreatedBefore
+	if before.IsZero() {
+		before = time.Now()
+	}
+	if after.IsZero() && q.CreatedBefore.IsZero() {
+		after = before.AddDate(0, -1, 0)
+	}
+
+	return after, before
+}
+
+// comparisonQuery returns the query of the period to compare with.
+// It returns nil if no comparison is requested.
+func (q *Query) comparisonQuery() (*Query, error) {
+	var after, before time.Time
+
+	switch strings.ToLower(q.Compare) {
+	case "":
+		return nil, nil
+
+	case "previous":
+		after, before = q.period()
+		if after.IsZero() {
+			return nil, rpcstatus.Error(codes.InvalidArgument, "createdAfter is required to compare with the previous period.")
+		}
+
+		// The created filters include both ends, so the previous period ends a second before the period
+		// to not count an item created at its start in both periods.
+		d := before.Sub(after)
+		after, before = after.Add(-d), after.Add(-time.Second)
+
+	case "custom":
+		after, before = q.CompareAfter, q.CompareBefore
+		if after.IsZero() || before.IsZero() {
+			return nil, rpcstatus.Error(codes.InvalidArgument, "compareAfter and compareBefore are required for a custom comparison.")
+		}
+
+	default:
+		return nil, rpcstatus.Error(codes.InvalidArgument, "compare must be one of: previous, custom.")
+	}
+
+	if !after.Before(before) {
+		return nil, rpcstatus.Error(codes.InvalidArgument, "The comparison period must end after it starts.")
+	}
+
+	return &Query{
+		CreatedAfter:  after,
+		CreatedBefore: before,
+		Product:       q.Product,
+	}, nil
 }
 
 func (q *Query) String() string {
@@ -348,6 +826,18 @@ func (q *Query) ToCAFinalQueryString() string {
 	return s
 }
 
+// openAppInFilter returns the filter of the App-In of the query open at any time between after and before.
+func (q *Query) openAppInFilter(after, before time.Time) string {
+	created := &Query{Product: q.Product, CreatedBefore: before}
+	return created.String() + fmt.Sprintf(` and (fields/CompletedDateTime eq null or fields/CompletedDateTime ge '%s')`, after.Format(time.RFC3339))
+}
+
+// openCAFinalFilter returns the filter of the CA Final open at any time between after and before.
+func (q *Query) openCAFinalFilter(after, before time.Time) string {
+	created := &Query{CreatedBefore: before}
+	return created.ToCAFinalQueryString() + fmt.Sprintf(` and (fields/FinalEndTime eq null or fields/FinalEndTime ge '%s')`, after.Format(time.RFC3339))
+}
+
 type rawAppIn struct {
 	LONumber           string     `json:"LOFacility"`
 	Product            string     `json:"ServiceType"`
@@ -380,6 +870,22 @@ type CAFinal struct {
 	Status      string     `json:"status"`
 	CompletedAt *time.Time `json:"completedAt"`
 	CreatedAt   time.Time  `json:"createdAt"`
+
+	// Product and Type are the product and customer type from the configured columns or the App-In of the loan.
+	// Empty if unknown. See CAFinalColumns.
+	Product string `json:"product"`
+	Type    string `json:"type"`
+
+	// Reason is why the CA Final was not converted from the configured reason column.
+	Reason string `json:"reason"`
+
+	// Age is the time from creation to completion, or to now if not completed.
+	Age time.Duration `json:"age"`
+
+	// Outcome is the category of Status in the status taxonomy.
+	Outcome Outcome `json:"outcome"`
+
+	unmapped bool
 }
 
 type AppIn struct {
@@ -396,10 +902,26 @@ type AppIn struct {
 	CreatedBy          string     `json:"createdBy"`
 	CompletedAt        *time.Time `json:"completedAt"`
 	CreatedAt          time.Time  `json:"createdAt"`
+
+	// Age is the time from creation to completion, or to now if not completed.
+	Age time.Duration `json:"age"`
+
+	// FinanceAmountValue is the parsed FinanceAmount. Nil if empty or invalid.
+	FinanceAmountValue *Decimal `json:"financeAmountValue"`
+
+	// TermMonths is the parsed Term. Nil if empty or invalid.
+	TermMonths *int64 `json:"termMonths"`
+
+	// Outcome is the category of Status in the status taxonomy.
+	Outcome Outcome `json:"outcome"`
+
+	amountErr error
+	termErr   error
+	unmapped  bool
 }
 
 func newAppInFromRawAppIn(a *rawAppIn) *AppIn {
-	return &AppIn{
+	app := &AppIn{
 		Number:             a.LONumber,
 		Product:            a.Product,
 		Type:               a.Type,
@@ -413,7 +935,26 @@ func newAppInFromRawAppIn(a *rawAppIn) *AppIn {
 		CreatedBy:          a.CreatedBy,
 		CompletedAt:        a.CompletedAt,
 		CreatedAt:          a.CreatedAt,
+		Age:                age(a.CreatedAt, a.CompletedAt),
 	}
+
+	if strings.TrimSpace(a.FinanceAmount) != "" {
+		if v, err := ParseDecimal(a.FinanceAmount); err != nil {
+			app.amountErr = err
+		} else {
+			app.FinanceAmountValue = &v
+		}
+	}
+
+	if strings.TrimSpace(a.Term) != "" {
+		if v, err := ParseTerm(a.Term); err != nil {
+			app.termErr = err
+		} else {
+			app.TermMonths = &v
+		}
+	}
+
+	return app
 }
 
 func newAppInFromRawCAFinal(a *rawCAFinal) *CAFinal {
@@ -424,5 +965,6 @@ func newAppInFromRawCAFinal(a *rawCAFinal) *CAFinal {
 		Executor:    a.Executor,
 		CompletedAt: a.CompletedAt,
 		CreatedAt:   a.CreatedAt,
+		Age:         age(a.CreatedAt, a.CompletedAt),
 	}
 }
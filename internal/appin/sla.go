@@ -0,0 +1,326 @@
+package appin
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Stage is a stage of the App-In process.
+type Stage string
+
+const (
+	// StageAppIn is the App-In stage.
+	StageAppIn Stage = "appin"
+
+	// StageCAFinal is the CA Final stage.
+	StageCAFinal Stage = "cafinal"
+)
+
+// Duration is a time.Duration that is encoded in JSON as a string. ex: "5h", "30m".
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string: %w", err)
+	}
+
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(v)
+	return nil
+}
+
+// SLARule is the SLA target of a stage, product and customer type.
+// An empty Stage, Product or CustomerType matches any value.
+type SLARule struct {
+	// Stage is the stage the rule applies to.
+	Stage Stage `json:"stage"`
+
+	// Product is the product the rule applies to. ex: "Sale Auto"
+	Product string `json:"product"`
+
+	// CustomerType is the customer type the rule applies to. ex: "C4C_Normal"
+	CustomerType string `json:"customerType"`
+
+	// Target is the maximum duration before an item breaches the SLA.
+	Target Duration `json:"target"`
+
+	// Warning is the duration after which a pending item is at risk of breaching the SLA.
+	// Zero means no warning: pending items are never at risk.
+	Warning Duration `json:"warning"`
+}
+
+func (r *SLARule) matches(stage Stage, product, customerType string) bool {
+	if r.Stage != "" && r.Stage != stage {
+		return false
+	}
+	if r.Product != "" && !strings.EqualFold(r.Product, product) {
+		return false
+	}
+	if r.CustomerType != "" && !strings.EqualFold(r.CustomerType, customerType) {
+		return false
+	}
+
+	return true
+}
+
+// specificity is the number of fields the rule matches on.
+func (r *SLARule) specificity() int {
+	n := 0
+	for _, v := range []string{string(r.Stage), r.Product, r.CustomerType} {
+		if v != "" {
+			n++
+		}
+	}
+
+	return n
+}
+
+// SLAPolicy is the set of SLA rules.
+// The most specific matching rule wins, then the first one defined.
+type SLAPolicy struct {
+	// Default is used when no rule matches.
+	Default *SLARule `json:"default"`
+
+	Rules []*SLARule `json:"rules"`
+}
+
+// DefaultSLAPolicy returns the policy used when none is configured.
+// Every item must be completed within 5h and is at risk after 4h.
+func DefaultSLAPolicy() *SLAPolicy {
+	return &SLAPolicy{
+		Default: &SLARule{
+			Target:  Duration(5 * time.Hour),
+			Warning: Duration(4 * time.Hour),
+		},
+		Rules: make([]*SLARule, 0),
+	}
+}
+
+// ReadSLAPolicyFile reads an SLA policy from a JSON file.
+func ReadSLAPolicyFile(name string) (*SLAPolicy, error) {
+	byt, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sla policy: %w", err)
+	}
+
+	p := new(SLAPolicy)
+	if err := json.Unmarshal(byt, p); err != nil {
+		return nil, fmt.Errorf("failed to parse sla policy: %w", err)
+	}
+	if p.Default == nil {
+		p.Default = DefaultSLAPolicy().Default
+	}
+
+	return p, p.Validate()
+}
+
+func (p *SLAPolicy) Validate() error {
+	if p.Default == nil {
+		return fmt.Errorf("sla default rule is nil")
+	}
+
+	for i, r := range append([]*SLARule{p.Default}, p.Rules...) {
+		if r == nil {
+			return fmt.Errorf("sla rule %d is nil", i)
+		}
+		if r.Target <= 0 {
+			return fmt.Errorf("sla rule %d target must be positive", i)
+		}
+		if r.Warning < 0 || r.Warning > r.Target {
+			return fmt.Errorf("sla rule %d warning must be between 0 and the target", i)
+		}
+	}
+
+	return nil
+}
+
+// validateStages checks that every rule applies to App-In, CA Final or one of the stages.
+func (p *SLAPolicy) validateStages(stages StageConfigs) error {
+	known := stages.names()
+	for i, r := range append([]*SLARule{p.Default}, p.Rules...) {
+		if r.Stage != "" && !known[r.Stage] {
+			return fmt.Errorf("sla rule %d has unknown stage %q", i, r.Stage)
+		}
+	}
+
+	return nil
+}
+
+// rule returns the rule that applies to an item.
+func (p *SLAPolicy) rule(stage Stage, product, customerType string) *SLARule {
+	var best *SLARule
+	for _, r := range p.Rules {
+		if !r.matches(stage, product, customerType) {
+			continue
+		}
+		if best == nil || r.specificity() > best.specificity() {
+			best = r
+		}
+	}
+
+	if best == nil {
+		return p.Default
+	}
+
+	return best
+}
+
+// SLAOverview is the SLA report of every stage.
+type SLAOverview struct {
+	// AppIn is the SLA report of App-In.
+	AppIn *SLAReport `json:"appIn"`
+
+	// CAFinal is the SLA report of CA Final.
+	CAFinal *SLAReport `json:"caFinal"`
+}
+
+// SLAReport is the SLA compliance of a stage.
+type SLAReport struct {
+	// Breached is the number of items that took or are taking longer than their target.
+	Breached int64 `json:"breached"`
+
+	// AtRisk is the number of pending items past their warning but not yet their target.
+	AtRisk int64 `json:"atRisk"`
+
+	// Within is the number of items within their SLA.
+	Within int64 `json:"within"`
+
+	// Compliance is the percentage of items within their SLA. Within / (Breached + AtRisk + Within)
+	Compliance float32 `json:"compliance"`
+
+	// Breaches is the items that breached the SLA, the longest first.
+	Breaches []*SLABreach `json:"breaches"`
+
+	// Executors is the executors who own breaching or at-risk items, the most breaches first.
+	Executors []*SLAExecutor `json:"executors"`
+}
+
+// SLABreach is an item that breached the SLA.
+type SLABreach struct {
+	Number       string     `json:"number"`
+	DisplayName  string     `json:"displayName"`
+	Executor     string     `json:"executor"`
+	Product      string     `json:"product"`
+	CustomerType string     `json:"customerType"`
+	Status       string     `json:"status"`
+	Pending      bool       `json:"pending"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	CompletedAt  *time.Time `json:"completedAt"`
+
+	// Elapsed is the time taken, or the age if the item is pending.
+	Elapsed time.Duration `json:"elapsed"`
+
+	// Target is the SLA target of the item.
+	Target time.Duration `json:"target"`
+
+	// Overdue is the time past the target.
+	Overdue time.Duration `json:"overdue"`
+}
+
+// SLAExecutor is the SLA status of the items owned by an executor.
+type SLAExecutor struct {
+	DisplayName string `json:"displayName"`
+	Breached    int64  `json:"breached"`
+	AtRisk      int64  `json:"atRisk"`
+}
+
+// newSLAReport measures the pending and completed records against the SLA of their stage.
+func newSLAReport(rs []*Record, p *SLAPolicy) *SLAReport {
+	r := &SLAReport{
+		Breaches:  make([]*SLABreach, 0),
+		Executors: make([]*SLAExecutor, 0),
+	}
+
+	executors := make(map[string]*SLAExecutor)
+	executor := func(name string) *SLAExecutor {
+		e, ok := executors[name]
+		if !ok {
+			e = &SLAExecutor{DisplayName: name}
+			executors[name] = e
+		}
+		return e
+	}
+
+	now := time.Now()
+	for _, it := range rs {
+		pending := it.pending()
+		if !pending && it.CompletedAt == nil {
+			continue
+		}
+
+		rule := p.rule(it.Stage, it.Product, it.CustomerType)
+		target, warning := time.Duration(rule.Target), time.Duration(rule.Warning)
+
+		elapsed := now.Sub(it.CreatedAt)
+		if !pending {
+			elapsed = it.CompletedAt.Sub(it.CreatedAt)
+		}
+
+		switch {
+		case elapsed > target:
+			r.Breached++
+			r.Breaches = append(r.Breaches, &SLABreach{
+				Number:       it.Number,
+				DisplayName:  it.DisplayName,
+				Executor:     it.Executor,
+				Product:      it.Product,
+				CustomerType: it.CustomerType,
+				Status:       it.Status,
+				Pending:      pending,
+				CreatedAt:    it.CreatedAt,
+				CompletedAt:  it.CompletedAt,
+				Elapsed:      elapsed,
+				Target:       target,
+				Overdue:      elapsed - target,
+			})
+			if it.Executor != "" {
+				executor(it.Executor).Breached++
+			}
+
+		case pending && warning > 0 && elapsed > warning:
+			r.AtRisk++
+			if it.Executor != "" {
+				executor(it.Executor).AtRisk++
+			}
+
+		default:
+			r.Within++
+		}
+	}
+
+	if total := r.Breached + r.AtRisk + r.Within; total > 0 {
+		r.Compliance = float32(r.Within) / float32(total) * 100
+	}
+
+	sort.Slice(r.Breaches, func(i, j int) bool {
+		return r.Breaches[i].Overdue > r.Breaches[j].Overdue
+	})
+
+	for _, e := range executors {
+		r.Executors = append(r.Executors, e)
+	}
+	sort.Slice(r.Executors, func(i, j int) bool {
+		if r.Executors[i].Breached != r.Executors[j].Breached {
+			return r.Executors[i].Breached > r.Executors[j].Breached
+		}
+		if r.Executors[i].AtRisk != r.Executors[j].AtRisk {
+			return r.Executors[i].AtRisk > r.Executors[j].AtRisk
+		}
+		return r.Executors[i].DisplayName < r.Executors[j].DisplayName
+	})
+
+	return r
+}
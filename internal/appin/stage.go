@@ -0,0 +1,592 @@
+package appin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	core "github.com/microsoftgraph/msgraph-sdk-go-core"
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+	"github.com/microsoftgraph/msgraph-sdk-go/sites"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+)
+
+// Record is an item of any stage of the pipeline. The metrics of every stage are calculated from records.
+type Record struct {
+	Stage        Stage
+	Number       string
+	DisplayName  string
+	Executor     string
+	Status       string
+	Outcome      Outcome
+	Product      string
+	CustomerType string
+	CreatedBy    string
+	CreatedAt    time.Time
+	CompletedAt  *time.Time
+
+	// Reason is why the item was not converted. The status is used if empty.
+	Reason string
+
+	// Value is the finance amount of the item. Nil if unknown.
+	Value *Decimal
+
+	// Dimensions is the other configured columns of the item by name.
+	Dimensions map[string]string
+
+	unmapped bool
+}
+
+func (r *Record) converted() bool {
+	return r.Outcome == OutcomeConverted && r.CompletedAt != nil
+}
+
+func (r *Record) pending() bool {
+	return r.Outcome == OutcomePending && r.CompletedAt == nil
+}
+
+func (a *AppIn) record() *Record {
+	return &Record{
+		Stage:        StageAppIn,
+		Number:       a.Number,
+		DisplayName:  a.DisplayName,
+		Executor:     a.Executor,
+		Status:       a.Status,
+		Outcome:      a.Outcome,
+		Product:      a.Product,
+		CustomerType: a.Type,
+		CreatedBy:    a.CreatedBy,
+		CreatedAt:    a.CreatedAt,
+		CompletedAt:  a.CompletedAt,
+		Value:        a.FinanceAmountValue,
+		unmapped:     a.unmapped,
+	}
+}
+
+func (c *CAFinal) record() *Record {
+	return &Record{
+		Stage:        StageCAFinal,
+		Number:       c.Number,
+		DisplayName:  c.DisplayName,
+		Executor:     c.Executor,
+		Status:       c.Status,
+		Outcome:      c.Outcome,
+		Product:      c.Product,
+		CustomerType: c.Type,
+		CreatedAt:    c.CreatedAt,
+		CompletedAt:  c.CompletedAt,
+		Reason:       c.Reason,
+		unmapped:     c.unmapped,
+	}
+}
+
+// records returns the records of the items.
+func records[T interface{ record() *Record }](items []T) []*Record {
+	rs := make([]*Record, 0, len(items))
+	for _, item := range items {
+		rs = append(rs, item.record())
+	}
+
+	return rs
+}
+
+// StageConfig is a stage of the pipeline.
+// App-In and CA Final are built in. Other stages are read from a SharePoint list with the configured columns.
+// Outliers are excluded and unmapped statuses reported for every stage, but custom metrics only
+// apply to App-In and CA Final since their expressions use the fields of those lists.
+//
+// ex: a disbursement stage
+//
+//	{
+//	  "name": "disbursement",
+//	  "title": "Disbursement",
+//	  "listId": "...",
+//	  "columns": {
+//	    "number": "LoanNumber",
+//	    "executor": "DisbursedBy",
+//	    "status": "Status",
+//	    "createdAt": "Created",
+//	    "completedAt": "DisbursedAt",
+//	    "product": "Product"
+//	  },
+//	  "taxonomy": {"rules": [{"status": "disbursed", "outcome": "converted"}]}
+//	}
+type StageConfig struct {
+	// Name is the unique name of the stage. ex: "disbursement"
+	Name Stage `json:"name"`
+
+	// Title is the display name of the stage. ex: "Disbursement"
+	Title string `json:"title"`
+
+	// ListID is the SharePoint list of the stage.
+	ListID string `json:"listId"`
+
+	Columns *StageColumns `json:"columns"`
+
+	// Taxonomy maps the statuses of the stage to an outcome.
+	// The mapping of the stage in the status taxonomy is used if nil.
+	Taxonomy *StatusMapping `json:"taxonomy"`
+
+	// RejectedIsProcessed counts rejected items as processed in the conversion rate and average time.
+	// App-In counts them, CA Final does not.
+	RejectedIsProcessed bool `json:"rejectedIsProcessed"`
+}
+
+// StageColumns is the internal names of the SharePoint columns of a stage.
+// Number, Executor, Status and CreatedAt are required, others are not fetched if empty.
+type StageColumns struct {
+	Number       string `json:"number"`
+	DisplayName  string `json:"displayName"`
+	Executor     string `json:"executor"`
+	Status       string `json:"status"`
+	CreatedAt    string `json:"createdAt"`
+	CompletedAt  string `json:"completedAt"`
+	Product      string `json:"product"`
+	CustomerType string `json:"customerType"`
+	Reason       string `json:"reason"`
+	Value        string `json:"value"`
+
+	// Dimensions is the other columns of the stage by dimension name.
+	Dimensions map[string]string `json:"dimensions"`
+}
+
+// appInStage and caFinalStage are the built-in stages.
+var (
+	appInStage   = &StageConfig{Name: StageAppIn, Title: "App-In", RejectedIsProcessed: true}
+	caFinalStage = &StageConfig{Name: StageCAFinal, Title: "CA Final"}
+)
+
+// StageConfigs is the configured stages after App-In and CA Final, in pipeline order.
+type StageConfigs []*StageConfig
+
+// ReadStageConfigsFile reads stage configs from a JSON file.
+func ReadStageConfigsFile(name string) (StageConfigs, error) {
+	byt, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stages: %w", err)
+	}
+
+	var sc StageConfigs
+	if err := json.Unmarshal(byt, &sc); err != nil {
+		return nil, fmt.Errorf("failed to parse stages: %w", err)
+	}
+
+	return sc, sc.Validate()
+}
+
+func (sc StageConfigs) Validate() error {
+	names := map[Stage]bool{StageAppIn: true, StageCAFinal: true}
+	for _, st := range sc {
+		if st.Name == "" {
+			return fmt.Errorf("stage name is empty")
+		}
+		if names[st.Name] {
+			return fmt.Errorf("stage %q is duplicated", st.Name)
+		}
+		names[st.Name] = true
+
+		if st.ListID == "" {
+			return fmt.Errorf("stage %q has no list id", st.Name)
+		}
+
+		c := st.Columns
+		if c == nil || c.Number == "" || c.Executor == "" || c.Status == "" || c.CreatedAt == "" {
+			return fmt.Errorf("stage %q needs the number, executor, status and createdAt columns", st.Name)
+		}
+
+		if st.Taxonomy != nil {
+			if err := (StatusTaxonomy{st.Name: st.Taxonomy}).Validate(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// names returns the names of App-In, CA Final and the stages.
+func (sc StageConfigs) names() map[Stage]bool {
+	names := map[Stage]bool{StageAppIn: true, StageCAFinal: true}
+	for _, st := range sc {
+		names[st.Name] = true
+	}
+
+	return names
+}
+
+// withStages returns a copy of the taxonomy with the mapping of each stage that has its own.
+// It fails if a stage has no mapping.
+func (t StatusTaxonomy) withStages(sc StageConfigs) (StatusTaxonomy, error) {
+	c := make(StatusTaxonomy, len(t)+len(sc))
+	for stage, m := range t {
+		c[stage] = m
+	}
+
+	for _, st := range sc {
+		if st.Taxonomy != nil {
+			c[st.Name] = st.Taxonomy
+		}
+		if c[st.Name] == nil {
+			return nil, fmt.Errorf("stage %q has no status taxonomy", st.Name)
+		}
+	}
+
+	return c, nil
+}
+
+// names returns the configured column names.
+func (c *StageColumns) names() []string {
+	names := make([]string, 0)
+	for _, n := range []string{c.Number, c.DisplayName, c.Executor, c.Status, c.CreatedAt, c.CompletedAt, c.Product, c.CustomerType, c.Reason, c.Value} {
+		if n != "" {
+			names = append(names, n)
+		}
+	}
+	for _, n := range c.Dimensions {
+		names = append(names, n)
+	}
+
+	return names
+}
+
+// newRecord returns the record of the raw fields of a list item of the stage.
+func (st *StageConfig) newRecord(fields map[string]any, t StatusTaxonomy) (*Record, error) {
+	c := st.Columns
+	str := func(name string) string {
+		return fieldString(fields, name)
+	}
+	tm := func(name string) (*time.Time, error) {
+		switch v := fields[name].(type) {
+		case time.Time:
+			return &v, nil
+		case *time.Time:
+			return v, nil
+		}
+
+		s := str(name)
+		if s == "" {
+			return nil, nil
+		}
+		v, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", name, err)
+		}
+		return &v, nil
+	}
+
+	createdAt, err := tm(c.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if createdAt == nil {
+		return nil, fmt.Errorf("%s is empty", c.CreatedAt)
+	}
+
+	completedAt, err := tm(c.CompletedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Record{
+		Stage:        st.Name,
+		Number:       str(c.Number),
+		DisplayName:  str(c.DisplayName),
+		Executor:     str(c.Executor),
+		Status:       str(c.Status),
+		Product:      str(c.Product),
+		CustomerType: str(c.CustomerType),
+		Reason:       str(c.Reason),
+		CreatedAt:    *createdAt,
+		CompletedAt:  completedAt,
+		Dimensions:   make(map[string]string, len(c.Dimensions)),
+	}
+
+	if v := str(c.Value); v != "" {
+		if d, err := ParseDecimal(v); err == nil {
+			r.Value = &d
+		}
+	}
+	for name, col := range c.Dimensions {
+		r.Dimensions[name] = str(col)
+	}
+
+	outcome, mapped := t.outcome(st.Name, r.Status)
+	r.Outcome = outcome
+	r.unmapped = !mapped
+
+	return r, nil
+}
+
+// StageOverview is the overview of a stage of the pipeline.
+type StageOverview struct {
+	// Stage is the name of the stage.
+	Stage Stage `json:"stage"`
+
+	// Title is the display name of the stage.
+	Title string `json:"title"`
+
+	// ActiveExecutor is the number of active executor.
+	ActiveExecutor int64 `json:"activeExecutor"`
+
+	// TopPerformer is the top performer of the stage.
+	TopPerformer *TopPerformer `json:"topPerformer"`
+
+	// Conversion is the conversion rate.
+	Conversion *Conversion `json:"conversion"`
+
+	// TimeIntervalsByConverted is the time intervals for converted items.
+	TimeIntervalsByConverted []*TimeInterval `json:"timeIntervalsByConverted"`
+
+	// TimeIntervalsByPending is the time intervals for pending items.
+	TimeIntervalsByPending []*TimeInterval `json:"timeIntervalsByPending"`
+
+	// BestTimeUsed is the executor with the best time used for the stage.
+	BestTimeUsed *BestTimeExecutor `json:"bestTimeUsed"`
+
+	// Leaderboard is the leaderboard of the stage.
+	// Top 5 performers unless LeaderboardOptions says otherwise.
+	Leaderboards []*Leaderboard `json:"leaderboards"`
+
+	// LeaderboardTotal is the number of executors eligible for the leaderboard.
+	LeaderboardTotal int64 `json:"leaderboardTotal"`
+
+	// SLA is the SLA compliance of the stage.
+	SLA *SLAReport `json:"sla"`
+
+	// ProductMetrics is the metrics of each product label of the grouping scheme.
+	// Items without a known product are grouped as the grouping scheme says.
+	ProductMetrics []*ProductMetrics `json:"productMetrics"`
+
+	// Rejections is the rejected, cancelled and returned items by reason.
+	Rejections []*Rejection `json:"rejections"`
+
+	// Comparison is the change against the comparison period.
+	// Nil when no comparison is requested.
+	Comparison *Comparison `json:"comparison"`
+
+	config *StageConfig
+}
+
+// newStageOverview calculates the overview of the records of a stage.
+func newStageOverview(st *StageConfig, rs []*Record, opts *OverviewOptions) *StageOverview {
+	groups := groupByExecutor(rs)
+	performances := calculateConversionMetricsByExecutor(st, groups, opts.SLA)
+
+	o := &StageOverview{
+		Stage:  st.Name,
+		Title:  st.Title,
+		config: st,
+	}
+
+	o.ActiveExecutor = int64(len(groups))
+	o.TopPerformer = getTopPerformer(performances)
+	o.Leaderboards, o.LeaderboardTotal = createLeaderboards(performances, opts.Leaderboard)
+	o.BestTimeUsed = findBestTimeUsedByExecutor(performances)
+
+	o.Conversion = newConversion(st, rs, opts.SLA)
+	o.TimeIntervalsByConverted = createTimeIntervalsByConverted(rs, nil)
+	o.TimeIntervalsByPending = createTimeIntervalsByPending(rs)
+	o.SLA = newSLAReport(rs, opts.SLA)
+	o.ProductMetrics = calculateConversionMetricsByProduct(st, rs, opts.SLA, opts.Grouping)
+	o.Rejections = newRejections(rs)
+
+	return o
+}
+
+// fieldString returns the raw field of a list item as a trimmed string. Empty if missing.
+func fieldString(fields map[string]any, name string) string {
+	if name == "" {
+		return ""
+	}
+
+	switch v := fields[name].(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case *string:
+		if v != nil {
+			return strings.TrimSpace(*v)
+		}
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case *float64:
+		if v != nil {
+			return strconv.FormatFloat(*v, 'f', -1, 64)
+		}
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case *int64:
+		if v != nil {
+			return strconv.FormatInt(*v, 10)
+		}
+	case int32:
+		return strconv.FormatInt(int64(v), 10)
+	case *int32:
+		if v != nil {
+			return strconv.FormatInt(int64(*v), 10)
+		}
+	case bool:
+		return strconv.FormatBool(v)
+	case *bool:
+		if v != nil {
+			return strconv.FormatBool(*v)
+		}
+	}
+
+	return ""
+}
+
+// stageFilter returns the OData filter of the created range of the query on the stage.
+func (st *StageConfig) stageFilter(q *Query) string {
+	after, before := q.period()
+	col := st.Columns.CreatedAt
+
+	s := fmt.Sprintf(`fields/%s le '%s'`, col, before.Format(time.RFC3339))
+	if !after.IsZero() {
+		s = fmt.Sprintf(`fields/%s ge '%s' and `, col, after.Format(time.RFC3339)) + s
+	}
+
+	return s
+}
+
+func (st *StageConfig) reqConfig(q *Query) *sites.ItemListsItemItemsRequestBuilderGetRequestConfiguration {
+	return &sites.ItemListsItemItemsRequestBuilderGetRequestConfiguration{
+		QueryParameters: &sites.ItemListsItemItemsRequestBuilderGetQueryParameters{
+			Expand: []string{
+				`fields($select=` + strings.Join(st.Columns.names(), ",") + `)`,
+			},
+			Filter: to.Ptr(st.stageFilter(q)),
+			Orderby: []string{
+				"fields/" + st.Columns.CreatedAt + " desc",
+			},
+			Top: to.Ptr[int32](500),
+		},
+	}
+}
+
+// listRecords lists the records of the stage matching the query.
+// Items that cannot be read are logged and skipped.
+func (s *Service) listRecords(ctx context.Context, st *StageConfig, q *Query) ([]*Record, error) {
+	zlog := s.zlog.With(
+		zap.String("method", "listRecords"),
+		zap.String("stage", string(st.Name)),
+		zap.Any("query", q),
+	)
+
+	rs := make([]*Record, 0)
+	res, err := s.client.Sites().
+		BySiteId(s.siteID).
+		Lists().
+		ByListId(st.ListID).
+		Items().
+		Get(ctx, st.reqConfig(q))
+	if err != nil {
+		zlog.Error("failed to get list items", zap.Error(err))
+		return nil, err
+	}
+
+	pager, err := core.NewPageIterator[*models.ListItem](res, s.client.GetAdapter(), models.CreateListItemCollectionResponseFromDiscriminatorValue)
+	if err != nil {
+		zlog.Error("failed to create page iterator", zap.Error(err))
+		return nil, err
+	}
+
+	if err := pager.Iterate(ctx, func(l *models.ListItem) bool {
+		if l.GetFields() == nil {
+			return false
+		}
+
+		r, err := st.newRecord(l.GetFields().GetAdditionalData(), s.taxonomy)
+		if err != nil {
+			zlog.Warn("failed to read list item", zap.Error(err))
+			return true
+		}
+		r.Executor = s.executors.resolve(r.Executor)
+
+		if q.Product == "" || strings.EqualFold(r.Product, q.Product) {
+			rs = append(rs, r)
+		}
+		return true
+	}); err != nil {
+		zlog.Error("failed to iterate page", zap.Error(err))
+		return nil, err
+	}
+
+	return rs, nil
+}
+
+// listConfiguredStages lists the records of each configured stage matching the query concurrently.
+func (s *Service) listConfiguredStages(ctx context.Context, q *Query) ([][]*Record, error) {
+	rs := make([][]*Record, len(s.stages))
+
+	g, ctx := errgroup.WithContext(ctx)
+	for i, st := range s.stages {
+		g.Go(func() (err error) {
+			rs[i], err = s.listRecords(ctx, st, q)
+			return err
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	return rs, nil
+}
+
+// setStages sets the overview of each configured stage, compared against the comparison query if not nil.
+// It returns the records of each configured stage the overviews are calculated from.
+func (s *Service) setStages(ctx context.Context, o *Overview, q, cq *Query, opts *OverviewOptions) ([][]*Record, error) {
+	o.Stages = make([]*StageOverview, 0, len(s.stages))
+	if len(s.stages) == 0 {
+		return nil, nil
+	}
+
+	var rs, prs [][]*Record
+	g, gctx := errgroup.WithContext(ctx)
+
+	g.Go(func() (err error) {
+		rs, err = s.listConfiguredStages(gctx, q)
+		return
+	})
+
+	if cq != nil {
+		g.Go(func() (err error) {
+			prs, err = s.listConfiguredStages(gctx, cq)
+			return
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	for i, st := range s.stages {
+		rs[i] = withoutOutliers(rs[i], opts)
+		o.Stages = append(o.Stages, newStageOverview(st, rs[i], opts))
+	}
+
+	if cq == nil {
+		return rs, nil
+	}
+
+	for i, so := range o.Stages {
+		so.SetComparison(cq, withoutOutliers(prs[i], opts), opts)
+	}
+
+	return rs, nil
+}
+
+// withoutOutliers returns the records of a configured stage without their outliers if the options exclude them.
+func withoutOutliers(rs []*Record, opts *OverviewOptions) []*Record {
+	if !opts.ExcludeOutliers {
+		return rs
+	}
+
+	return without(rs, flagged(recordOutliers(rs, opts.OutlierMethod)))
+}
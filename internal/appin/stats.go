@@ -0,0 +1,62 @@
+package appin
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Percentiles is the distribution of the time used for converted items.
+type Percentiles struct {
+	P50 time.Duration `json:"p50"`
+	P75 time.Duration `json:"p75"`
+	P90 time.Duration `json:"p90"`
+	P95 time.Duration `json:"p95"`
+}
+
+func newPercentiles(ds []time.Duration) *Percentiles {
+	sorted := sortDurations(ds)
+	return &Percentiles{
+		P50: percentile(sorted, 50),
+		P75: percentile(sorted, 75),
+		P90: percentile(sorted, 90),
+		P95: percentile(sorted, 95),
+	}
+}
+
+// sortDurations returns a sorted copy of ds.
+func sortDurations(ds []time.Duration) []time.Duration {
+	sorted := make([]time.Duration, len(ds))
+	copy(sorted, ds)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted
+}
+
+// percentile returns the p-th percentile of sorted durations using the nearest-rank method.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+
+	return sorted[rank-1]
+}
+
+// convertedDurations returns the time used for each converted record.
+func convertedDurations(rs []*Record) []time.Duration {
+	ds := make([]time.Duration, 0, len(rs))
+	for _, r := range rs {
+		if r.converted() {
+			ds = append(ds, r.CompletedAt.Sub(r.CreatedAt))
+		}
+	}
+
+	return ds
+}
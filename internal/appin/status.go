@@ -0,0 +1,233 @@
+package appin
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Outcome is the category of a raw status.
+type Outcome string
+
+const (
+	OutcomeConverted Outcome = "converted"
+	OutcomeRejected  Outcome = "rejected"
+	OutcomeCancelled Outcome = "cancelled"
+	OutcomePending   Outcome = "pending"
+	OutcomeReturned  Outcome = "returned"
+)
+
+func (o Outcome) valid() bool {
+	switch o {
+	case OutcomeConverted, OutcomeRejected, OutcomeCancelled, OutcomePending, OutcomeReturned:
+		return true
+	default:
+		return false
+	}
+}
+
+// StatusTaxonomy maps the raw statuses of each stage to an outcome.
+type StatusTaxonomy map[Stage]*StatusMapping
+
+// StatusMapping maps the raw statuses of a stage to an outcome.
+type StatusMapping struct {
+	// Empty is the outcome of an empty status.
+	Empty Outcome `json:"empty"`
+
+	// Unmapped is the outcome of a status no rule matches. Such statuses are reported.
+	Unmapped Outcome `json:"unmapped"`
+
+	// Rules is matched in order, the first match wins.
+	Rules []*StatusRule `json:"rules"`
+}
+
+// StatusRule maps a raw status to an outcome. Statuses are compared case-insensitively.
+type StatusRule struct {
+	// Status is the raw status. ex: "Not Pass"
+	Status string `json:"status"`
+
+	// Contains matches any status containing Status instead of equal to it.
+	Contains bool `json:"contains"`
+
+	Outcome Outcome `json:"outcome"`
+}
+
+// DefaultStatusTaxonomy returns the taxonomy used when none is configured.
+// Statuses containing "not pass", "cancel" or "return" are rejected, cancelled or returned.
+// An App-In is converted when its status is "completed" or contains "pass", a CA Final when its status is "completed".
+// Any other status is pending and reported as unmapped.
+func DefaultStatusTaxonomy() StatusTaxonomy {
+	return StatusTaxonomy{
+		StageAppIn: {
+			Empty:    OutcomePending,
+			Unmapped: OutcomePending,
+			Rules: []*StatusRule{
+				{Status: "not pass", Contains: true, Outcome: OutcomeRejected},
+				{Status: "cancel", Contains: true, Outcome: OutcomeCancelled},
+				{Status: "return", Contains: true, Outcome: OutcomeReturned},
+				{Status: "completed", Outcome: OutcomeConverted},
+				{Status: "pass", Contains: true, Outcome: OutcomeConverted},
+			},
+		},
+		StageCAFinal: {
+			Empty:    OutcomePending,
+			Unmapped: OutcomePending,
+			Rules: []*StatusRule{
+				{Status: "cancel", Contains: true, Outcome: OutcomeCancelled},
+				{Status: "return", Contains: true, Outcome: OutcomeReturned},
+				{Status: "completed", Outcome: OutcomeConverted},
+			},
+		},
+	}
+}
+
+// ReadStatusTaxonomyFile reads a status taxonomy from a JSON file.
+// Stages missing from the file use the default mapping.
+func ReadStatusTaxonomyFile(name string) (StatusTaxonomy, error) {
+	byt, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read status taxonomy: %w", err)
+	}
+
+	t := make(StatusTaxonomy)
+	if err := json.Unmarshal(byt, &t); err != nil {
+		return nil, fmt.Errorf("failed to parse status taxonomy: %w", err)
+	}
+
+	for stage, m := range DefaultStatusTaxonomy() {
+		if _, ok := t[stage]; !ok {
+			t[stage] = m
+		}
+	}
+
+	return t, t.Validate()
+}
+
+func (t StatusTaxonomy) Validate() error {
+	for stage, m := range t {
+		if m == nil {
+			return fmt.Errorf("status mapping of %q is nil", stage)
+		}
+		if !m.Empty.valid() {
+			return fmt.Errorf("status mapping of %q has unknown empty outcome %q", stage, m.Empty)
+		}
+		if !m.Unmapped.valid() {
+			return fmt.Errorf("status mapping of %q has unknown unmapped outcome %q", stage, m.Unmapped)
+		}
+		for i, r := range m.Rules {
+			if r == nil || r.Status == "" {
+				return fmt.Errorf("status rule %d of %q has no status", i, stage)
+			}
+			if !r.Outcome.valid() {
+				return fmt.Errorf("status rule %d of %q has unknown outcome %q", i, stage, r.Outcome)
+			}
+		}
+	}
+
+	return nil
+}
+
+// outcome returns the outcome of a raw status of the stage and whether a rule matched it.
+func (t StatusTaxonomy) outcome(stage Stage, status string) (Outcome, bool) {
+	m, ok := t[stage]
+	if !ok {
+		m = DefaultStatusTaxonomy()[stage]
+	}
+
+	status = strings.ToLower(strings.TrimSpace(status))
+	if status == "" {
+		return m.Empty, true
+	}
+
+	for _, r := range m.Rules {
+		match := strings.ToLower(r.Status)
+		if status == match || (r.Contains && strings.Contains(status, match)) {
+			return r.Outcome, true
+		}
+	}
+
+	return m.Unmapped, false
+}
+
+// UnmappedStatus is a raw status no rule of the taxonomy matches.
+type UnmappedStatus struct {
+	Stage   Stage   `json:"stage"`
+	Status  string  `json:"status"`
+	Outcome Outcome `json:"outcome"`
+	Count   int64   `json:"count"`
+}
+
+// newUnmappedStatuses returns the unmapped statuses of the records of every stage, the most frequent first.
+func newUnmappedStatuses(rs []*Record) []*UnmappedStatus {
+	counts := make(map[Stage]map[string]*UnmappedStatus)
+	add := func(stage Stage, status string, outcome Outcome) {
+		if counts[stage] == nil {
+			counts[stage] = make(map[string]*UnmappedStatus)
+		}
+		u, ok := counts[stage][status]
+		if !ok {
+			u = &UnmappedStatus{Stage: stage, Status: status, Outcome: outcome}
+			counts[stage][status] = u
+		}
+		u.Count++
+	}
+
+	for _, r := range rs {
+		if r.unmapped {
+			add(r.Stage, r.Status, r.Outcome)
+		}
+	}
+
+	us := make([]*UnmappedStatus, 0)
+	for _, byStatus := range counts {
+		for _, u := range byStatus {
+			us = append(us, u)
+		}
+	}
+
+	sort.Slice(us, func(i, j int) bool {
+		if us[i].Count != us[j].Count {
+			return us[i].Count > us[j].Count
+		}
+		if us[i].Stage != us[j].Stage {
+			return us[i].Stage < us[j].Stage
+		}
+		return us[i].Status < us[j].Status
+	})
+
+	return us
+}
+
+func (a *AppIn) setOutcome(t StatusTaxonomy) {
+	outcome, mapped := t.outcome(StageAppIn, a.Status)
+	a.Outcome = outcome
+	a.unmapped = !mapped
+}
+
+func (c *CAFinal) setOutcome(t StatusTaxonomy) {
+	outcome, mapped := t.outcome(StageCAFinal, c.Status)
+	c.Outcome = outcome
+	c.unmapped = !mapped
+}
+
+// converted reports whether the App-In is converted with a completed time.
+func (a *AppIn) converted() bool {
+	return a.Outcome == OutcomeConverted && a.CompletedAt != nil
+}
+
+// pending reports whether the App-In is waiting to be processed.
+func (a *AppIn) pending() bool {
+	return a.Outcome == OutcomePending && a.CompletedAt == nil
+}
+
+// converted reports whether the CA Final is converted with a completed time.
+func (c *CAFinal) converted() bool {
+	return c.Outcome == OutcomeConverted && c.CompletedAt != nil
+}
+
+// pending reports whether the CA Final is waiting to be processed.
+func (c *CAFinal) pending() bool {
+	return c.Outcome == OutcomePending && c.CompletedAt == nil
+}
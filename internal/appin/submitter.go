@@ -0,0 +1,174 @@
+package appin
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	rpcstatus "google.golang.org/grpc/status"
+)
+
+// SubmitterMetrics is the quality of the App-In submitted by a person.
+type SubmitterMetrics struct {
+	// DisplayName is the display name of the submitter.
+	DisplayName string `json:"displayName"`
+
+	// Total is the number of App-In submitted.
+	Total int64 `json:"total"`
+
+	// Converted is the number of submitted App-In converted.
+	Converted int64 `json:"converted"`
+
+	// NotPassed is the number of submitted App-In not passed.
+	NotPassed int64 `json:"notPassed"`
+
+	// ConversionRate is the percentage of submitted App-In converted. Converted / Total
+	ConversionRate float32 `json:"conversionRate"`
+
+	// NotPassRate is the percentage of submitted App-In not passed. NotPassed / Total
+	NotPassRate float32 `json:"notPassRate"`
+
+	// AverageTurnaround is the average time the executors used for the completed App-In.
+	AverageTurnaround time.Duration `json:"averageTurnaround"`
+
+	// CustomerTypes is the customer type mix of the submitted App-In, the most frequent first.
+	CustomerTypes []*CustomerTypeShare `json:"customerTypes"`
+}
+
+// CustomerTypeShare is the share of a customer type.
+type CustomerTypeShare struct {
+	Name    string  `json:"name"`
+	Total   int64   `json:"total"`
+	Percent float32 `json:"percent"`
+}
+
+// SubmitterOptions is how the submitters are paged, apart from the leaderboards.
+type SubmitterOptions struct {
+	// Limit is the maximum number of submitters. Defaults to 5.
+	Limit int
+
+	// Offset is the number of submitters to skip.
+	Offset int
+
+	// MinVolume is the minimum number of App-In for a submitter to be listed.
+	MinVolume int64
+}
+
+const maxSubmitterLimit = 500
+
+// submitterOptions returns the submitter options of the query.
+func (q *Query) submitterOptions() (*SubmitterOptions, error) {
+	o := &SubmitterOptions{Limit: 5}
+
+	if q.SubmitterLimit != 0 {
+		if q.SubmitterLimit < 0 || q.SubmitterLimit > maxSubmitterLimit {
+			return nil, rpcstatus.Error(codes.InvalidArgument, fmt.Sprintf("submitterLimit must be between 1 and %d.", maxSubmitterLimit))
+		}
+		o.Limit = q.SubmitterLimit
+	}
+
+	if q.SubmitterOffset < 0 {
+		return nil, rpcstatus.Error(codes.InvalidArgument, "submitterOffset must not be negative.")
+	}
+	o.Offset = q.SubmitterOffset
+
+	if q.SubmitterMinVolume < 0 {
+		return nil, rpcstatus.Error(codes.InvalidArgument, "submitterMinVolume must not be negative.")
+	}
+	o.MinVolume = q.SubmitterMinVolume
+
+	return o, nil
+}
+
+// createSubmitterMetrics creates the metrics of each submitter, the most App-In first.
+// It returns the page described by the submitter options and the number of submitters.
+func createSubmitterMetrics(appIns []*AppIn, so *SubmitterOptions) ([]*SubmitterMetrics, int64) {
+	groups := make(map[string][]*AppIn)
+	for _, a := range appIns {
+		if a.CreatedBy == "" {
+			continue
+		}
+
+		groups[a.CreatedBy] = append(groups[a.CreatedBy], a)
+	}
+
+	ms := make([]*SubmitterMetrics, 0, len(groups))
+	for submitter, as := range groups {
+		if int64(len(as)) < so.MinVolume {
+			continue
+		}
+
+		ms = append(ms, newSubmitterMetrics(submitter, as))
+	}
+
+	sort.Slice(ms, func(i, j int) bool {
+		if ms[i].Total != ms[j].Total {
+			return ms[i].Total > ms[j].Total
+		}
+		if ms[i].ConversionRate != ms[j].ConversionRate {
+			return ms[i].ConversionRate > ms[j].ConversionRate
+		}
+		return ms[i].DisplayName < ms[j].DisplayName
+	})
+
+	total := int64(len(ms))
+	if so.Offset >= len(ms) {
+		return make([]*SubmitterMetrics, 0), total
+	}
+
+	ms = ms[so.Offset:]
+	if len(ms) > so.Limit {
+		ms = ms[:so.Limit]
+	}
+
+	return ms, total
+}
+
+func newSubmitterMetrics(submitter string, appIns []*AppIn) *SubmitterMetrics {
+	m := &SubmitterMetrics{
+		DisplayName: submitter,
+		Total:       int64(len(appIns)),
+	}
+
+	types := make(map[string]int64)
+	var sum time.Duration
+	var completed int64
+	for _, a := range appIns {
+		types[a.Type]++
+
+		if a.converted() {
+			m.Converted++
+		}
+		if a.Outcome == OutcomeRejected {
+			m.NotPassed++
+		}
+		if a.CompletedAt != nil && !a.pending() {
+			sum += a.CompletedAt.Sub(a.CreatedAt)
+			completed++
+		}
+	}
+
+	m.ConversionRate = rate(m.Converted, m.Total)
+	m.NotPassRate = rate(m.NotPassed, m.Total)
+	if completed > 0 {
+		m.AverageTurnaround = sum / time.Duration(completed)
+	}
+
+	m.CustomerTypes = make([]*CustomerTypeShare, 0, len(types))
+	for name, n := range types {
+		m.CustomerTypes = append(m.CustomerTypes, &CustomerTypeShare{
+			Name:    name,
+			Total:   n,
+			Percent: rate(n, m.Total),
+		})
+	}
+	sort.Slice(m.CustomerTypes, func(i, j int) bool {
+		if m.CustomerTypes[i].Total != m.CustomerTypes[j].Total {
+			return m.CustomerTypes[i].Total > m.CustomerTypes[j].Total
+		}
+		return m.CustomerTypes[i].Name < m.CustomerTypes[j].Name
+	})
+
+	return m
+}
@@ -0,0 +1,175 @@
+package appin
+
+import (
+	"sort"
+	"time"
+)
+
+// ValueOverview is the loan value financed by App-In.
+type ValueOverview struct {
+	// Overall is the value of every App-In.
+	Overall *ValueMetrics `json:"overall"`
+
+	// ByProduct is the value of each product, the largest total first.
+	ByProduct []*ValueMetrics `json:"byProduct"`
+
+	// ByExecutor is the value handled by each executor, the largest total first.
+	ByExecutor []*ValueMetrics `json:"byExecutor"`
+
+	// ByPeriod is the value of each period of the query interval, the earliest first.
+	ByPeriod []*ValueMetrics `json:"byPeriod"`
+
+	// ParseFailures is the App-In whose finance amount or term could not be parsed.
+	ParseFailures []*ParseFailure `json:"parseFailures"`
+}
+
+// ValueMetrics is the loan value of a group of App-In.
+type ValueMetrics struct {
+	// Name is the name of the group. ex: product name, executor or period.
+	Name string `json:"name"`
+
+	// Count is the number of App-In with a parsed finance amount.
+	Count int64 `json:"count"`
+
+	// Total is the total finance amount.
+	Total Decimal `json:"total"`
+
+	// Average is the average finance amount.
+	Average Decimal `json:"average"`
+
+	// Median is the median finance amount.
+	Median Decimal `json:"median"`
+
+	// Converted is the total finance amount of converted App-In.
+	Converted Decimal `json:"converted"`
+
+	// ConvertedCount is the number of converted App-In with a parsed finance amount.
+	ConvertedCount int64 `json:"convertedCount"`
+
+	// AverageTerm is the average term in months of the App-In with a parsed term.
+	AverageTerm float32 `json:"averageTerm"`
+}
+
+// ParseFailure is a field of an App-In that could not be parsed.
+type ParseFailure struct {
+	Number string `json:"number"`
+	Field  string `json:"field"`
+	Value  string `json:"value"`
+	Error  string `json:"error"`
+}
+
+func newValueOverview(appIns []*AppIn, interval Interval, loc *time.Location) *ValueOverview {
+	byProduct := make(map[string][]*AppIn)
+	byExecutor := make(map[string][]*AppIn)
+	byPeriod := make(map[string][]*AppIn)
+	failures := make([]*ParseFailure, 0)
+
+	for _, a := range appIns {
+		if a.amountErr != nil {
+			failures = append(failures, &ParseFailure{
+				Number: a.Number,
+				Field:  "financeAmount",
+				Value:  a.FinanceAmount,
+				Error:  a.amountErr.Error(),
+			})
+		}
+		if a.termErr != nil {
+			failures = append(failures, &ParseFailure{
+				Number: a.Number,
+				Field:  "term",
+				Value:  a.Term,
+				Error:  a.termErr.Error(),
+			})
+		}
+
+		if a.Product != "" {
+			byProduct[a.Product] = append(byProduct[a.Product], a)
+		}
+		if a.Executor != "" {
+			byExecutor[a.Executor] = append(byExecutor[a.Executor], a)
+		}
+		key := interval.key(a.CreatedAt, loc)
+		byPeriod[key] = append(byPeriod[key], a)
+	}
+
+	v := &ValueOverview{
+		Overall:       newValueMetrics("", appIns),
+		ByProduct:     newValueMetricsByGroup(byProduct),
+		ByExecutor:    newValueMetricsByGroup(byExecutor),
+		ByPeriod:      newValueMetricsByGroup(byPeriod),
+		ParseFailures: failures,
+	}
+
+	sort.Slice(v.ByPeriod, func(i, j int) bool {
+		return v.ByPeriod[i].Name < v.ByPeriod[j].Name
+	})
+
+	return v
+}
+
+// newValueMetricsByGroup returns the value of each group, the largest total first.
+func newValueMetricsByGroup(groups map[string][]*AppIn) []*ValueMetrics {
+	vs := make([]*ValueMetrics, 0, len(groups))
+	for name, as := range groups {
+		vs = append(vs, newValueMetrics(name, as))
+	}
+
+	sort.Slice(vs, func(i, j int) bool {
+		if vs[i].Total != vs[j].Total {
+			return vs[i].Total > vs[j].Total
+		}
+		return vs[i].Name < vs[j].Name
+	})
+
+	return vs
+}
+
+func newValueMetrics(name string, appIns []*AppIn) *ValueMetrics {
+	v := &ValueMetrics{Name: name}
+
+	amounts := make([]Decimal, 0, len(appIns))
+	var terms, termCount int64
+	for _, a := range appIns {
+		if a.TermMonths != nil {
+			terms += *a.TermMonths
+			termCount++
+		}
+
+		if a.FinanceAmountValue == nil {
+			continue
+		}
+
+		amount := *a.FinanceAmountValue
+		amounts = append(amounts, amount)
+		v.Count++
+		v.Total += amount
+
+		if a.converted() {
+			v.Converted += amount
+			v.ConvertedCount++
+		}
+	}
+
+	v.Average = v.Total.divide(v.Count)
+	v.Median = medianDecimal(amounts)
+	if termCount > 0 {
+		v.AverageTerm = float32(terms) / float32(termCount)
+	}
+
+	return v
+}
+
+func medianDecimal(ds []Decimal) Decimal {
+	if len(ds) == 0 {
+		return 0
+	}
+
+	sort.Slice(ds, func(i, j int) bool { return ds[i] < ds[j] })
+
+	mid := len(ds) / 2
+	if len(ds)%2 == 1 {
+		return ds[mid]
+	}
+
+	return (ds[mid-1] + ds[mid]).divide(2)
+}
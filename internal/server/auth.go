@@ -0,0 +1,75 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	rpcstatus "google.golang.org/grpc/status"
+)
+
+const (
+	// userHeader is the header of the user of a request, set by the authenticating proxy.
+	userHeader = "X-User"
+
+	// userTimestampHeader is the Unix time in seconds the proxy signed the user at.
+	userTimestampHeader = "X-User-Timestamp"
+
+	// userSignatureHeader is the hex-encoded HMAC-SHA256 of "<user>\n<timestamp>" with the secret shared with the proxy.
+	userSignatureHeader = "X-User-Signature"
+
+	// maxUserSignatureAge is how long a signed user is accepted, so a leaked signature cannot be replayed for long.
+	maxUserSignatureAge = 5 * time.Minute
+)
+
+// UserVerifier verifies the user of a request signed by the authenticating proxy.
+// The user header alone can be set by any client, so it is only trusted with a valid signature.
+type UserVerifier struct {
+	secret []byte
+	now    func() time.Time
+}
+
+// NewUserVerifier returns a verifier of the users signed with the secret.
+// Every request is unauthenticated if the secret is empty.
+func NewUserVerifier(secret string) *UserVerifier {
+	return &UserVerifier{
+		secret: []byte(secret),
+		now:    time.Now,
+	}
+}
+
+// User returns the user of the request if its signature is valid and recent.
+func (v *UserVerifier) User(r *http.Request) (string, error) {
+	user := r.Header.Get(userHeader)
+	if user == "" || len(v.secret) == 0 {
+		return "", rpcstatus.Error(codes.Unauthenticated, "User is required.")
+	}
+
+	ts := r.Header.Get(userTimestampHeader)
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return "", rpcstatus.Error(codes.Unauthenticated, "User signature is invalid.")
+	}
+
+	age := v.now().Sub(time.Unix(sec, 0))
+	if age > maxUserSignatureAge || age < -maxUserSignatureAge {
+		return "", rpcstatus.Error(codes.Unauthenticated, "User signature has expired.")
+	}
+
+	sig, err := hex.DecodeString(r.Header.Get(userSignatureHeader))
+	if err != nil || !hmac.Equal(sig, v.sign(user, ts)) {
+		return "", rpcstatus.Error(codes.Unauthenticated, "User signature is invalid.")
+	}
+
+	return user, nil
+}
+
+func (v *UserVerifier) sign(user, ts string) []byte {
+	mac := hmac.New(sha256.New, v.secret)
+	mac.Write([]byte(user + "\n" + ts))
+	return mac.Sum(nil)
+}
@@ -3,8 +3,12 @@ package server
 import (
 	"errors"
 	"net/http"
+	"net/url"
+	"strings"
 
+	"github.com/10664kls/app-in-performance-api/internal/alert"
 	"github.com/10664kls/app-in-performance-api/internal/appin"
+	"github.com/10664kls/app-in-performance-api/internal/view"
 	"github.com/labstack/echo/v4"
 	edpb "google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
@@ -12,16 +16,47 @@ import (
 )
 
 type Server struct {
-	appin *appin.Service
+	appin     *appin.Service
+	alerts    *alert.Engine
+	views     *view.Service
+	executors *appin.ExecutorDirectory
+	users     *UserVerifier
+
+	// admins is the users allowed to manage the executor directory and silence alerts.
+	admins map[string]bool
 }
 
-func NewServer(appin *appin.Service) (*Server, error) {
+func NewServer(appin *appin.Service, alerts *alert.Engine, views *view.Service, executors *appin.ExecutorDirectory, users *UserVerifier, admins []string) (*Server, error) {
 	if appin == nil {
 		return nil, errors.New("appin is nil")
 	}
+	if alerts == nil {
+		return nil, errors.New("alerts is nil")
+	}
+	if views == nil {
+		return nil, errors.New("views is nil")
+	}
+	if executors == nil {
+		return nil, errors.New("executors is nil")
+	}
+	if users == nil {
+		return nil, errors.New("users is nil")
+	}
+
+	adminSet := make(map[string]bool, len(admins))
+	for _, a := range admins {
+		if a = strings.TrimSpace(a); a != "" {
+			adminSet[a] = true
+		}
+	}
 
 	return &Server{
-		appin: appin,
+		appin:     appin,
+		alerts:    alerts,
+		views:     views,
+		executors: executors,
+		users:     users,
+		admins:    adminSet,
 	}, nil
 }
 
@@ -34,6 +69,34 @@ func (s *Server) Install(e *echo.Echo, mws ...echo.MiddlewareFunc) error {
 
 	v1.GET("/appins", s.listAppIns, mws...)
 	v1.GET("/appins/overview", s.getAppInOverview, mws...)
+	v1.GET("/appins/pivot", s.getAppInPivot, mws...)
+	v1.GET("/cafinals", s.listCAFinals, mws...)
+	v1.GET("/cafinals/overview", s.getCAFinalOverview, mws...)
+	v1.GET("/sla", s.getSLAReport, mws...)
+	v1.GET("/funnel", s.getFunnel, mws...)
+	v1.GET("/backlog", s.getBacklog, mws...)
+	v1.GET("/heatmap", s.getHeatmap, mws...)
+	v1.GET("/outliers", s.getOutliers, mws...)
+	v1.GET("/forecast", s.getForecast, mws...)
+	v1.GET("/metrics/catalog", s.listMetricCatalog, mws...)
+
+	v1.GET("/executors", s.listExecutors, mws...)
+	v1.GET("/executors/:name", s.getExecutor, mws...)
+
+	v1.GET("/alerts", s.listAlerts, mws...)
+	v1.POST("/alerts/:id/silence", s.silenceAlert, mws...)
+	v1.DELETE("/alerts/:id/silence", s.unsilenceAlert, mws...)
+
+	v1.GET("/views", s.listViews, mws...)
+	v1.POST("/views", s.createView, mws...)
+	v1.GET("/views/:id", s.getView, mws...)
+	v1.PUT("/views/:id", s.updateView, mws...)
+	v1.DELETE("/views/:id", s.deleteView, mws...)
+
+	v1.GET("/admin/executors", s.listExecutorIdentities, mws...)
+	v1.POST("/admin/executors/merge", s.mergeExecutors, mws...)
+	v1.PUT("/admin/executors/:id", s.putExecutorIdentity, mws...)
+	v1.DELETE("/admin/executors/:id", s.deleteExecutorIdentity, mws...)
 
 	return nil
 }
@@ -58,10 +121,36 @@ func badParam() error {
 	return s.Err()
 }
 
-func (s *Server) listAppIns(c echo.Context) error {
+// bindQuery binds the query parameters of the request.
+// With a view parameter the query of the view is used and the other parameters override it.
+func (s *Server) bindQuery(c echo.Context) (*appin.Query, error) {
 	req := new(appin.Query)
+	if id := c.QueryParam("view"); id != "" {
+		user, err := s.users.User(c.Request())
+		if err != nil {
+			return nil, err
+		}
+
+		v, err := s.views.GetView(c.Request().Context(), user, id)
+		if err != nil {
+			return nil, err
+		}
+		if v.Query != nil {
+			*req = *v.Query
+		}
+	}
+
 	if err := c.Bind(req); err != nil {
-		return badParam()
+		return nil, badParam()
+	}
+
+	return req, nil
+}
+
+func (s *Server) listAppIns(c echo.Context) error {
+	req, err := s.bindQuery(c)
+	if err != nil {
+		return err
 	}
 
 	as, err := s.appin.ListAppIns(c.Request().Context(), req)
@@ -72,10 +161,56 @@ func (s *Server) listAppIns(c echo.Context) error {
 	return c.JSON(http.StatusOK, as)
 }
 
+func (s *Server) listCAFinals(c echo.Context) error {
+	req, err := s.bindQuery(c)
+	if err != nil {
+		return err
+	}
+
+	cs, err := s.appin.ListCAFinals(c.Request().Context(), req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, cs)
+}
+
+func (s *Server) getCAFinalOverview(c echo.Context) error {
+	req, err := s.bindQuery(c)
+	if err != nil {
+		return err
+	}
+
+	o, err := s.appin.GetCAFinalOverview(c.Request().Context(), req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"overview": o,
+	})
+}
+
+func (s *Server) getAppInPivot(c echo.Context) error {
+	req, err := s.bindQuery(c)
+	if err != nil {
+		return err
+	}
+
+	p, err := s.appin.GetPivot(c.Request().Context(), req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"pivot": p,
+	})
+}
+
 func (s *Server) getAppInOverview(c echo.Context) error {
-	req := new(appin.Query)
-	if err := c.Bind(req); err != nil {
-		return badParam()
+	req, err := s.bindQuery(c)
+	if err != nil {
+		return err
 	}
 
 	as, err := s.appin.GetOverview(c.Request().Context(), req)
@@ -87,3 +222,345 @@ func (s *Server) getAppInOverview(c echo.Context) error {
 		"overview": as,
 	})
 }
+
+func (s *Server) getSLAReport(c echo.Context) error {
+	req, err := s.bindQuery(c)
+	if err != nil {
+		return err
+	}
+
+	r, err := s.appin.GetSLAReport(c.Request().Context(), req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"sla": r,
+	})
+}
+
+func (s *Server) listExecutors(c echo.Context) error {
+	req, err := s.bindQuery(c)
+	if err != nil {
+		return err
+	}
+
+	es, err := s.appin.ListExecutors(c.Request().Context(), req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, es)
+}
+
+func (s *Server) getExecutor(c echo.Context) error {
+	name, err := url.PathUnescape(c.Param("name"))
+	if err != nil {
+		return badParam()
+	}
+
+	req, err := s.bindQuery(c)
+	if err != nil {
+		return err
+	}
+
+	e, err := s.appin.GetExecutor(c.Request().Context(), name, req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"executor": e,
+	})
+}
+
+func (s *Server) getFunnel(c echo.Context) error {
+	req, err := s.bindQuery(c)
+	if err != nil {
+		return err
+	}
+
+	f, err := s.appin.GetFunnel(c.Request().Context(), req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"funnel": f,
+	})
+}
+
+func (s *Server) getBacklog(c echo.Context) error {
+	req, err := s.bindQuery(c)
+	if err != nil {
+		return err
+	}
+
+	b, err := s.appin.GetBacklog(c.Request().Context(), req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"backlog": b,
+	})
+}
+
+func (s *Server) getHeatmap(c echo.Context) error {
+	req, err := s.bindQuery(c)
+	if err != nil {
+		return err
+	}
+
+	h, err := s.appin.GetHeatmap(c.Request().Context(), req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"heatmap": h,
+	})
+}
+
+func (s *Server) getOutliers(c echo.Context) error {
+	req, err := s.bindQuery(c)
+	if err != nil {
+		return err
+	}
+
+	o, err := s.appin.GetOutliers(c.Request().Context(), req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"outliers": o,
+	})
+}
+
+func (s *Server) getForecast(c echo.Context) error {
+	req, err := s.bindQuery(c)
+	if err != nil {
+		return err
+	}
+
+	f, err := s.appin.GetForecast(c.Request().Context(), req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"forecast": f,
+	})
+}
+
+func (s *Server) listMetricCatalog(c echo.Context) error {
+	return c.JSON(http.StatusOK, s.appin.ListMetricCatalog(c.Request().Context()))
+}
+
+func (s *Server) listAlerts(c echo.Context) error {
+	return c.JSON(http.StatusOK, s.alerts.ListAlerts(c.Request().Context()))
+}
+
+func (s *Server) silenceAlert(c echo.Context) error {
+	if err := s.requireAdmin(c); err != nil {
+		return err
+	}
+
+	req := new(alert.SilenceRequest)
+	if err := c.Bind(req); err != nil {
+		return badJSON()
+	}
+
+	a, err := s.alerts.SilenceAlert(c.Request().Context(), c.Param("id"), req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"alert": a,
+	})
+}
+
+func (s *Server) unsilenceAlert(c echo.Context) error {
+	if err := s.requireAdmin(c); err != nil {
+		return err
+	}
+
+	a, err := s.alerts.UnsilenceAlert(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"alert": a,
+	})
+}
+
+func (s *Server) listViews(c echo.Context) error {
+	user, err := s.users.User(c.Request())
+	if err != nil {
+		return err
+	}
+
+	vs, err := s.views.ListViews(c.Request().Context(), user)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, vs)
+}
+
+func (s *Server) getView(c echo.Context) error {
+	user, err := s.users.User(c.Request())
+	if err != nil {
+		return err
+	}
+
+	v, err := s.views.GetView(c.Request().Context(), user, c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"view": v,
+	})
+}
+
+func (s *Server) createView(c echo.Context) error {
+	user, err := s.users.User(c.Request())
+	if err != nil {
+		return err
+	}
+
+	req := new(view.ViewRequest)
+	if err := c.Bind(req); err != nil {
+		return badJSON()
+	}
+
+	v, err := s.views.CreateView(c.Request().Context(), user, req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, echo.Map{
+		"view": v,
+	})
+}
+
+func (s *Server) updateView(c echo.Context) error {
+	user, err := s.users.User(c.Request())
+	if err != nil {
+		return err
+	}
+
+	req := new(view.ViewRequest)
+	if err := c.Bind(req); err != nil {
+		return badJSON()
+	}
+
+	v, err := s.views.UpdateView(c.Request().Context(), user, c.Param("id"), req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"view": v,
+	})
+}
+
+func (s *Server) deleteView(c echo.Context) error {
+	user, err := s.users.User(c.Request())
+	if err != nil {
+		return err
+	}
+
+	if err := s.views.DeleteView(c.Request().Context(), user, c.Param("id")); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// requireAdmin checks the signed user of the request is an admin.
+func (s *Server) requireAdmin(c echo.Context) error {
+	user, err := s.users.User(c.Request())
+	if err != nil {
+		return err
+	}
+	if !s.admins[user] {
+		return rpcstatus.Error(codes.PermissionDenied, "Only admins can do this.")
+	}
+
+	return nil
+}
+
+func (s *Server) listExecutorIdentities(c echo.Context) error {
+	if err := s.requireAdmin(c); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, s.executors.ListExecutorIdentities(c.Request().Context()))
+}
+
+func (s *Server) putExecutorIdentity(c echo.Context) error {
+	if err := s.requireAdmin(c); err != nil {
+		return err
+	}
+
+	id, err := url.PathUnescape(c.Param("id"))
+	if err != nil {
+		return badParam()
+	}
+
+	req := new(appin.ExecutorIdentityRequest)
+	if err := c.Bind(req); err != nil {
+		return badJSON()
+	}
+
+	e, err := s.executors.PutExecutorIdentity(c.Request().Context(), id, req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"executor": e,
+	})
+}
+
+func (s *Server) deleteExecutorIdentity(c echo.Context) error {
+	if err := s.requireAdmin(c); err != nil {
+		return err
+	}
+
+	id, err := url.PathUnescape(c.Param("id"))
+	if err != nil {
+		return badParam()
+	}
+
+	if err := s.executors.DeleteExecutorIdentity(c.Request().Context(), id); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (s *Server) mergeExecutors(c echo.Context) error {
+	if err := s.requireAdmin(c); err != nil {
+		return err
+	}
+
+	req := new(appin.MergeExecutorsRequest)
+	if err := c.Bind(req); err != nil {
+		return badJSON()
+	}
+
+	e, err := s.executors.MergeExecutors(c.Request().Context(), req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"executor": e,
+	})
+}
@@ -0,0 +1,193 @@
+package view
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/10664kls/app-in-performance-api/internal/atomicfile"
+)
+
+var (
+	// ErrNotFound is returned by a store when a view does not exist.
+	ErrNotFound = errors.New("view not found")
+
+	// ErrConflict is returned by a store when a view was changed since it was read.
+	ErrConflict = errors.New("view was changed")
+)
+
+// Store persists views.
+type Store interface {
+	// List lists every view.
+	List(ctx context.Context) ([]*View, error)
+
+	// Get returns the view of the ID or ErrNotFound.
+	Get(ctx context.Context, id string) (*View, error)
+
+	// Create creates the view. It returns ErrConflict if a view of the ID exists.
+	Create(ctx context.Context, v *View) error
+
+	// Update replaces the view of its ID if it was last updated at updatedAt.
+	// It returns ErrNotFound if the view does not exist and ErrConflict if it was updated since.
+	Update(ctx context.Context, v *View, updatedAt time.Time) error
+
+	// Delete deletes the view of the ID if it was last updated at updatedAt.
+	// It returns ErrNotFound if the view does not exist and ErrConflict if it was updated since.
+	Delete(ctx context.Context, id string, updatedAt time.Time) error
+}
+
+// FileStore stores views in a local JSON file.
+// The whole file is rewritten on every change, so it suits a few thousand views at most.
+type FileStore struct {
+	name string
+
+	mu    sync.Mutex
+	views map[string]*View
+}
+
+// NewFileStore returns a store of the file. The file is created on the first change if it does not exist.
+func NewFileStore(name string) (*FileStore, error) {
+	s := &FileStore{
+		name:  name,
+		views: make(map[string]*View),
+	}
+
+	byt, err := os.ReadFile(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read views: %w", err)
+	}
+
+	var views []*View
+	if err := json.Unmarshal(byt, &views); err != nil {
+		return nil, fmt.Errorf("failed to parse views: %w", err)
+	}
+	for _, v := range views {
+		s.views[v.ID] = v
+	}
+
+	return s, nil
+}
+
+func (s *FileStore) List(_ context.Context) ([]*View, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.list(), nil
+}
+
+func (s *FileStore) Get(_ context.Context, id string) (*View, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v, ok := s.views[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return v.clone(), nil
+}
+
+func (s *FileStore) Create(_ context.Context, v *View) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.views[v.ID]; ok {
+		return ErrConflict
+	}
+
+	s.views[v.ID] = v.clone()
+	if err := s.save(); err != nil {
+		delete(s.views, v.ID)
+		return err
+	}
+
+	return nil
+}
+
+func (s *FileStore) Update(_ context.Context, v *View, updatedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous, err := s.current(v.ID, updatedAt)
+	if err != nil {
+		return err
+	}
+
+	s.views[v.ID] = v.clone()
+	if err := s.save(); err != nil {
+		s.views[v.ID] = previous
+		return err
+	}
+
+	return nil
+}
+
+func (s *FileStore) Delete(_ context.Context, id string, updatedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v, err := s.current(id, updatedAt)
+	if err != nil {
+		return err
+	}
+
+	delete(s.views, id)
+	if err := s.save(); err != nil {
+		s.views[id] = v
+		return err
+	}
+
+	return nil
+}
+
+// current returns the view of the ID if it was last updated at updatedAt.
+func (s *FileStore) current(id string, updatedAt time.Time) (*View, error) {
+	v, ok := s.views[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if !v.UpdatedAt.Equal(updatedAt) {
+		return nil, ErrConflict
+	}
+
+	return v, nil
+}
+
+// list returns a copy of the views, the oldest first.
+func (s *FileStore) list() []*View {
+	views := make([]*View, 0, len(s.views))
+	for _, v := range s.views {
+		views = append(views, v.clone())
+	}
+	sort.Slice(views, func(i, j int) bool {
+		if !views[i].CreatedAt.Equal(views[j].CreatedAt) {
+			return views[i].CreatedAt.Before(views[j].CreatedAt)
+		}
+		return views[i].ID < views[j].ID
+	})
+
+	return views
+}
+
+// save writes the views to a temporary file and renames it over the file,
+// so a crash never leaves a partially written file.
+func (s *FileStore) save() error {
+	byt, err := json.MarshalIndent(s.list(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal views: %w", err)
+	}
+
+	if err := atomicfile.WriteFile(s.name, byt); err != nil {
+		return fmt.Errorf("failed to save views: %w", err)
+	}
+
+	return nil
+}
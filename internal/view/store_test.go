@@ -0,0 +1,78 @@
+package view
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileStoreConflicts(t *testing.T) {
+	created := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	updated := created.Add(time.Hour)
+
+	tests := []struct {
+		name      string
+		id        string
+		updatedAt time.Time
+		delete    bool
+		wantErr   error
+		wantName  string
+	}{
+		{name: "update as read", id: "v1", updatedAt: created, wantName: "Renamed"},
+		{name: "update of a stale view", id: "v1", updatedAt: created.Add(-time.Second), wantErr: ErrConflict, wantName: "Backlog"},
+		{name: "update of a missing view", id: "v2", updatedAt: created, wantErr: ErrNotFound, wantName: "Backlog"},
+		{name: "delete as read", id: "v1", updatedAt: created, delete: true},
+		{name: "delete of a stale view", id: "v1", updatedAt: updated, delete: true, wantErr: ErrConflict, wantName: "Backlog"},
+		{name: "delete of a missing view", id: "v2", updatedAt: created, delete: true, wantErr: ErrNotFound, wantName: "Backlog"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			name := filepath.Join(t.TempDir(), "views.json")
+
+			s, err := NewFileStore(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Create(ctx, &View{ID: "v1", Name: "Backlog", CreatedAt: created, UpdatedAt: created}); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Create(ctx, &View{ID: "v1", Name: "Duplicate"}); !errors.Is(err, ErrConflict) {
+				t.Fatalf("Create of an existing ID error = %v, want %v", err, ErrConflict)
+			}
+
+			if tt.delete {
+				err = s.Delete(ctx, tt.id, tt.updatedAt)
+			} else {
+				err = s.Update(ctx, &View{ID: tt.id, Name: "Renamed", CreatedAt: created, UpdatedAt: updated}, tt.updatedAt)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+
+			// The change, or its absence, must be the same once the file is read again.
+			reloaded, err := NewFileStore(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range []*FileStore{s, reloaded} {
+				v, err := s.Get(ctx, "v1")
+				if tt.wantName == "" {
+					if !errors.Is(err, ErrNotFound) {
+						t.Errorf("Get error = %v, want %v", err, ErrNotFound)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if v.Name != tt.wantName {
+					t.Errorf("name = %q, want %q", v.Name, tt.wantName)
+				}
+			}
+		})
+	}
+}
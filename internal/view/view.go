@@ -0,0 +1,300 @@
+package view
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/10664kls/app-in-performance-api/internal/appin"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	rpcstatus "google.golang.org/grpc/status"
+)
+
+// maxNameLength is the maximum number of characters of the name of a view.
+const maxNameLength = 100
+
+// View is a saved query of the overview and list endpoints with the metrics its dashboard shows.
+type View struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+
+	// Owner is the user who created the view. Only the owner can change or delete it.
+	Owner string `json:"owner"`
+
+	// Shared makes the view visible to every user.
+	Shared bool `json:"shared"`
+
+	// Query is the filters, grouping, comparison and list options of the view.
+	Query *appin.Query `json:"query"`
+
+	// Metrics is the ID of each metric of the catalog the dashboard of the view shows, in order.
+	Metrics []string `json:"metrics"`
+
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func (v *View) clone() *View {
+	c := *v
+	if v.Query != nil {
+		q := *v.Query
+		c.Query = &q
+	}
+	c.Metrics = slices.Clone(v.Metrics)
+	return &c
+}
+
+// visible reports whether the user can see the view.
+func (v *View) visible(user string) bool {
+	return v.Shared || v.Owner == user
+}
+
+// QueryValidator checks the queries and metrics of views.
+type QueryValidator interface {
+	ValidateQuery(q *appin.Query) error
+	ListMetricCatalog(ctx context.Context) *appin.ListMetricCatalogResult
+}
+
+// Service manages the views of the users.
+type Service struct {
+	queries QueryValidator
+	store   Store
+	zlog    *zap.Logger
+}
+
+func NewService(queries QueryValidator, store Store, zlog *zap.Logger) (*Service, error) {
+	if queries == nil {
+		return nil, fmt.Errorf("queries is nil")
+	}
+	if store == nil {
+		return nil, fmt.Errorf("store is nil")
+	}
+	if zlog == nil {
+		return nil, fmt.Errorf("zlog is nil")
+	}
+
+	return &Service{
+		queries: queries,
+		store:   store,
+		zlog:    zlog,
+	}, nil
+}
+
+type ListViewsResult struct {
+	Views []*View `json:"views"`
+}
+
+// ListViews lists the views of the user and the shared views, the oldest first.
+func (s *Service) ListViews(ctx context.Context, user string) (*ListViewsResult, error) {
+	if err := requireUser(user); err != nil {
+		return nil, err
+	}
+
+	all, err := s.store.List(ctx)
+	if err != nil {
+		s.zlog.Error("failed to list views", zap.Error(err))
+		return nil, err
+	}
+
+	views := make([]*View, 0, len(all))
+	for _, v := range all {
+		if v.visible(user) {
+			views = append(views, v)
+		}
+	}
+
+	return &ListViewsResult{
+		Views: views,
+	}, nil
+}
+
+// GetView returns the view if the user owns it or it is shared.
+func (s *Service) GetView(ctx context.Context, user, id string) (*View, error) {
+	if err := requireUser(user); err != nil {
+		return nil, err
+	}
+
+	v, err := s.store.Get(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return nil, rpcstatus.Error(codes.NotFound, "View not found.")
+	}
+	if err != nil {
+		s.zlog.Error("failed to get view", zap.String("id", id), zap.Error(err))
+		return nil, err
+	}
+
+	if !v.visible(user) {
+		return nil, rpcstatus.Error(codes.NotFound, "View not found.")
+	}
+
+	return v, nil
+}
+
+type ViewRequest struct {
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Shared      bool         `json:"shared"`
+	Query       *appin.Query `json:"query"`
+	Metrics     []string     `json:"metrics"`
+}
+
+func (s *Service) validate(ctx context.Context, req *ViewRequest) error {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return rpcstatus.Error(codes.InvalidArgument, "name must not be empty.")
+	}
+	if len([]rune(name)) > maxNameLength {
+		return rpcstatus.Error(codes.InvalidArgument, fmt.Sprintf("name must not be longer than %d characters.", maxNameLength))
+	}
+
+	if req.Query == nil {
+		req.Query = new(appin.Query)
+	}
+	if err := s.queries.ValidateQuery(req.Query); err != nil {
+		return err
+	}
+
+	ids := make(map[string]bool)
+	for _, m := range s.queries.ListMetricCatalog(ctx).Metrics {
+		ids[m.ID] = true
+	}
+	for _, id := range req.Metrics {
+		if !ids[id] {
+			return rpcstatus.Error(codes.InvalidArgument, fmt.Sprintf("Metric %s is not in the metric catalog.", id))
+		}
+	}
+
+	return nil
+}
+
+// CreateView saves a new view owned by the user.
+func (s *Service) CreateView(ctx context.Context, user string, req *ViewRequest) (*View, error) {
+	if err := requireUser(user); err != nil {
+		return nil, err
+	}
+	if err := s.validate(ctx, req); err != nil {
+		return nil, err
+	}
+
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	v := &View{
+		ID:          id,
+		Name:        strings.TrimSpace(req.Name),
+		Description: req.Description,
+		Owner:       user,
+		Shared:      req.Shared,
+		Query:       req.Query,
+		Metrics:     req.Metrics,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := s.store.Create(ctx, v); err != nil {
+		s.zlog.Error("failed to create view", zap.Error(err))
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// UpdateView replaces the view owned by the user.
+// It fails if the view was changed or deleted by another request in the meantime.
+func (s *Service) UpdateView(ctx context.Context, user, id string, req *ViewRequest) (*View, error) {
+	v, err := s.ownedView(ctx, user, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.validate(ctx, req); err != nil {
+		return nil, err
+	}
+
+	updatedAt := v.UpdatedAt
+	v.Name = strings.TrimSpace(req.Name)
+	v.Description = req.Description
+	v.Shared = req.Shared
+	v.Query = req.Query
+	v.Metrics = req.Metrics
+	v.UpdatedAt = time.Now()
+	if err := s.store.Update(ctx, v, updatedAt); err != nil {
+		if err := storeError(err); err != nil {
+			return nil, err
+		}
+		s.zlog.Error("failed to update view", zap.String("id", id), zap.Error(err))
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// DeleteView deletes the view owned by the user.
+// It fails if the view was changed by another request in the meantime.
+func (s *Service) DeleteView(ctx context.Context, user, id string) error {
+	v, err := s.ownedView(ctx, user, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.Delete(ctx, id, v.UpdatedAt); err != nil {
+		if err := storeError(err); err != nil {
+			return err
+		}
+		s.zlog.Error("failed to delete view", zap.String("id", id), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+// storeError returns the status of a not found or conflicting view, or nil for other errors of the store.
+func storeError(err error) error {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return rpcstatus.Error(codes.NotFound, "View not found.")
+	case errors.Is(err, ErrConflict):
+		return rpcstatus.Error(codes.Aborted, "View was changed by another request. Reload it and try again.")
+	default:
+		return nil
+	}
+}
+
+// ownedView returns the view if the user owns it.
+func (s *Service) ownedView(ctx context.Context, user, id string) (*View, error) {
+	v, err := s.GetView(ctx, user, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if v.Owner != user {
+		return nil, rpcstatus.Error(codes.PermissionDenied, "Only the owner can change the view.")
+	}
+
+	return v, nil
+}
+
+func requireUser(user string) error {
+	if user == "" {
+		return rpcstatus.Error(codes.Unauthenticated, "User is required.")
+	}
+
+	return nil
+}
+
+func newID() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate view id: %w", err)
+	}
+
+	return hex.EncodeToString(b), nil
+}